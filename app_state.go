@@ -11,11 +11,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand/v2"
-	"os"
+	"math"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2/data/binding"
 )
@@ -37,6 +38,9 @@ type AppState struct {
 	Salary    binding.Int
 	Working   binding.Bool
 	Paused    binding.Bool
+	// Game over
+	GameOver       binding.Bool
+	GameOverReason binding.String
 	// Morning routine
 	RoutineShower     binding.Bool
 	RoutineShave      binding.Bool
@@ -46,16 +50,38 @@ type AppState struct {
 	ProgressEventName  binding.String
 	ProgressEventValue binding.Int
 	ProgressEventMax   binding.Int
+	progress           *progressHandlers
+	// Progress events waiting for the current one to finish
+	QueuedEvents binding.StringList
+	queue        []*progressHandlers
 	// Choice events
 	ChoiceEventName    binding.String
 	ChoiceEventText    binding.String
 	ChoiceEventChoices binding.StringList
 	// UI
 	Messages binding.StringList
-	Events   []Event
+	Events   []Event // in the order they are checked on every tick, see sortEvents
 	Buttons  binding.UntypedMap
 	// Custom variables
 	Variables binding.UntypedMap
+	// Time the state was saved at, zero for new games
+	savedAt time.Time
+	// Called for every event that fires, used to summarize offline progress
+	eventHook func(event *Event)
+	// All mods in load order, for the mod manager
+	mods []ModStatus
+	// Errors of the mods that were skipped when loading the scripts
+	scriptErrors ScriptErrors
+	// Events triggered by scripts or scheduled with @ that run at a
+	// later tick
+	timers TimerQueue
+	// Seeded random numbers, stored in the save by their seed
+	random *Random
+	// Runs of the events that have a cooldown or max
+	runLog EventRunLog
+	// Held while the game changes, by a tick and by the buttons and
+	// choices of the player, so their changes never interleave
+	mu sync.Mutex
 }
 
 // Adds a persistent button to the UI
@@ -74,100 +100,156 @@ func (a *AppState) RemoveButton(buttonText string) {
 	}
 }
 
+// Returns the persistent buttons as a map of button text to event name
+func (a *AppState) GetButtons() map[string]string {
+	buttons := map[string]string{}
+	// Buttons.Get() still contains deleted buttons, so go through the keys
+	for _, key := range a.Buttons.Keys() {
+		value, err := a.Buttons.GetValue(key)
+		if err != nil {
+			continue
+		}
+		if eventName, ok := value.(string); ok {
+			buttons[key] = eventName
+		}
+	}
+	return buttons
+}
+
+// Replaces all persistent buttons with the given buttons
+func (a *AppState) SetButtons(buttons map[string]string) {
+	values := make(map[string]any, len(buttons))
+	for buttonText, eventName := range buttons {
+		values[buttonText] = eventName
+	}
+	a.Buttons.Set(values)
+}
+
 // function so set app state variable via string name
 // for convenience, variable value will be kept within valid range, for example
 // between 0 and 100 for progress bar values
 // If the variable is not found, it will be created.
-func (a *AppState) Set(variable string, value interface{}) {
-	switch strings.ToLower(variable) {
+// Returns an error if the value has the wrong type for a built-in variable.
+func (a *AppState) Set(variable string, value interface{}) error {
+	name := strings.ToLower(variable)
+	switch name {
+	case "ticks", "work", "workxp", "food", "foodmax", "energy", "energymax",
+		"mood", "money", "charisma", "fitness", "salary", "routinebonus",
+		"eventvalue", "eventmax":
+		v, ok := value.(int)
+		if !ok {
+			return wrongTypeError(variable, "a whole number", value)
+		}
+		a.setInt(name, v)
+	case "job", "gameoverreason", "eventname":
+		v, ok := value.(string)
+		if !ok {
+			return wrongTypeError(variable, "text", value)
+		}
+		switch name {
+		case "job":
+			a.Job.Set(v)
+		case "gameoverreason":
+			a.GameOverReason.Set(v)
+		case "eventname":
+			a.ProgressEventName.Set(v)
+		}
+	case "working", "paused", "routineshower", "routineshave", "routinebrushteeth":
+		v, ok := value.(bool)
+		if !ok {
+			return wrongTypeError(variable, "true or false", value)
+		}
+		switch name {
+		case "working":
+			a.Working.Set(v)
+		case "paused":
+			a.Paused.Set(v)
+		case "routineshower":
+			a.RoutineShower.Set(v)
+		case "routineshave":
+			a.RoutineShave.Set(v)
+		case "routinebrushteeth":
+			a.RoutineBrushTeeth.Set(v)
+		}
+	case "gameover":
+		// scripts can end the game with a custom reason by setting
+		// gameOver to a string instead of true
+		switch v := value.(type) {
+		case bool:
+			if v {
+				a.SetGameOver(DefaultGameOverReason)
+			} else {
+				a.GameOverReason.Set("")
+				a.GameOver.Set(false)
+			}
+		case string:
+			a.SetGameOver(v)
+		default:
+			return wrongTypeError(variable, "true, false or text", value)
+		}
+	case "messages":
+		v, ok := value.([]string)
+		if !ok {
+			return wrongTypeError(variable, "a list of text", value)
+		}
+		a.Messages.Set(v)
+	case "events":
+		v, ok := value.([]Event)
+		if !ok {
+			return wrongTypeError(variable, "a list of events", value)
+		}
+		a.Events = v
+	default:
+		a.Variables.SetValue(variable, value)
+	}
+	return nil
+}
+
+// Sets a built-in int variable, keeping it within its valid range
+func (a *AppState) setInt(name string, v int) {
+	switch name {
 	case "ticks":
-		a.Ticks.Set(value.(int))
+		a.Ticks.Set(v)
 	case "work":
-		v := value.(int)
-		if v < 0 {
-			v = 0
-		} else if v > 100 {
-			v = 100
-		}
-		a.Work.Set(v)
+		a.Work.Set(min(max(v, 0), 100))
 	case "workxp":
-		a.WorkXP.Set(value.(int))
+		a.WorkXP.Set(v)
 	case "food":
-		a.Food.Set(value.(int))
+		a.Food.Set(v)
 	case "foodmax":
-		a.FoodMax.Set(value.(int))
+		a.FoodMax.Set(v)
 	case "energy":
 		energyMax, err := a.EnergyMax.Get()
 		if err != nil {
 			log.Println(err)
 			return
 		}
-		v := value.(int)
-		if v < 0 {
-			v = 0
-		} else if v > energyMax {
-			v = energyMax
-		}
-		a.Energy.Set(v)
+		a.Energy.Set(min(max(v, 0), energyMax))
 	case "energymax":
-		a.EnergyMax.Set(value.(int))
+		a.EnergyMax.Set(v)
 	case "mood":
-		v := value.(int)
-		if v < 0 {
-			v = 0
-		} else if v > 100 {
-			v = 100
-		}
-		a.Mood.Set(v)
+		a.Mood.Set(min(max(v, 0), 100))
 	case "money":
-		a.Money.Set(value.(int))
+		a.Money.Set(v)
 	case "charisma":
-		v := value.(int)
-		if v < 0 {
-			v = 0
-		} else if v > 100 {
-			v = 100
-		}
-		a.Charisma.Set(v)
+		a.Charisma.Set(min(max(v, 0), 100))
 	case "fitness":
-		v := value.(int)
-		if v < 0 {
-			v = 0
-		} else if v > 100 {
-			v = 100
-		}
-		a.Fitness.Set(v)
-	case "job":
-		a.Job.Set(value.(string))
+		a.Fitness.Set(min(max(v, 0), 100))
 	case "salary":
-		a.Salary.Set(value.(int))
-	case "working":
-		a.Working.Set(value.(bool))
-	case "paused":
-		a.Paused.Set(value.(bool))
-	case "routineshower":
-		a.RoutineShower.Set(value.(bool))
-	case "routineshave":
-		a.RoutineShave.Set(value.(bool))
-	case "routinebrushteeth":
-		a.RoutineBrushTeeth.Set(value.(bool))
+		a.Salary.Set(v)
 	case "routinebonus":
-		a.RoutineBonus.Set(value.(int))
-	case "eventname":
-		a.ProgressEventName.Set(value.(string))
+		a.RoutineBonus.Set(v)
 	case "eventvalue":
-		a.ProgressEventValue.Set(value.(int))
+		a.ProgressEventValue.Set(v)
 	case "eventmax":
-		a.ProgressEventMax.Set(value.(int))
-	case "messages":
-		a.Messages.Set(value.([]string))
-	case "events":
-		a.Events = value.([]Event)
-	default:
-		a.Variables.SetValue(variable, value)
+		a.ProgressEventMax.Set(v)
 	}
 }
 
+func wrongTypeError(variable, expected string, value interface{}) error {
+	return fmt.Errorf("%s must be %s, got %v (%T)", variable, expected, value, value)
+}
+
 // function to get an Event by name
 func (a *AppState) GetEvent(name string) *Event {
 	for i, event := range a.Events {
@@ -178,13 +260,40 @@ func (a *AppState) GetEvent(name string) *Event {
 	return nil
 }
 
+// Returns the names of all events that are done
+func (a *AppState) DoneEvents() []string {
+	names := []string{}
+	for _, event := range a.Events {
+		if event.Done {
+			names = append(names, event.Name)
+		}
+	}
+	return names
+}
+
+// Marks the events with the given names as done, names of events that
+// don't exist (anymore) are ignored
+func (a *AppState) SetDoneEvents(names []string) {
+	done := make(map[string]bool, len(names))
+	for _, name := range names {
+		done[name] = true
+	}
+	for i := range a.Events {
+		a.Events[i].Done = done[a.Events[i].Name]
+	}
+}
+
 // function to get app state variable via string name
 // to be used with script
 func (a *AppState) Get(variable string) interface{} {
 	switch strings.ToLower(variable) {
 	case "rand":
 		// special case that returns random number
-		return rand.Float64()
+		ticks, err := a.Ticks.Get()
+		if err != nil {
+			return nil
+		}
+		return a.random.Float64(ticks)
 	case "appearance":
 		// special case that returns appearance
 		return a.GetAppearance()
@@ -278,6 +387,18 @@ func (a *AppState) Get(variable string) interface{} {
 			return nil
 		}
 		return v
+	case "gameover":
+		v, err := a.GameOver.Get()
+		if err != nil {
+			return nil
+		}
+		return v
+	case "gameoverreason":
+		v, err := a.GameOverReason.Get()
+		if err != nil {
+			return nil
+		}
+		return v
 	case "routineshower":
 		v, err := a.RoutineShower.Get()
 		if err != nil {
@@ -330,7 +451,7 @@ func (a *AppState) Get(variable string) interface{} {
 	}
 }
 
-func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyValue, energyMaxValue, moodValue, charismaValue, moneyValue, fitnessValue int, job string, salary int, working bool, paused bool, routineShower bool, routineShave bool, routineBrushTeeth bool, routineBonus int, eventName string, eventValue int, eventMax int, choiceEventName string, choiceEventText string, choiceEventChoices []string, messages []string, variables map[string]any) *AppState {
+func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyValue, energyMaxValue, moodValue, charismaValue, moneyValue, fitnessValue int, job string, salary int, working bool, paused bool, gameOver bool, gameOverReason string, routineShower bool, routineShave bool, routineBrushTeeth bool, routineBonus int, eventName string, eventValue int, eventMax int, choiceEventName string, choiceEventText string, choiceEventChoices []string, messages []string, variables map[string]any) *AppState {
 	appstate := AppState{
 		Ticks:              binding.NewInt(),
 		Work:               binding.NewInt(),
@@ -347,6 +468,8 @@ func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyV
 		Salary:             binding.NewInt(),
 		Working:            binding.NewBool(),
 		Paused:             binding.NewBool(),
+		GameOver:           binding.NewBool(),
+		GameOverReason:     binding.NewString(),
 		RoutineShower:      binding.NewBool(),
 		RoutineShave:       binding.NewBool(),
 		RoutineBrushTeeth:  binding.NewBool(),
@@ -354,6 +477,7 @@ func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyV
 		ProgressEventName:  binding.NewString(),
 		ProgressEventValue: binding.NewInt(),
 		ProgressEventMax:   binding.NewInt(),
+		QueuedEvents:       binding.NewStringList(),
 		ChoiceEventName:    binding.NewString(),
 		ChoiceEventText:    binding.NewString(),
 		ChoiceEventChoices: binding.NewStringList(),
@@ -361,12 +485,13 @@ func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyV
 		Events:             []Event{},
 		Buttons:            binding.NewUntypedMap(),
 		Variables:          binding.NewUntypedMap(),
+		random:             NewRandom(0),
 	}
 	appstate.Ticks.Set(ticksValue)
 	appstate.Work.Set(workValue)
 	appstate.WorkXP.Set(workXP)
 	appstate.Food.Set(foodValue)
-	appstate.FoodMax.Set(foodValue)
+	appstate.FoodMax.Set(foodMaxValue)
 	appstate.Energy.Set(energyValue)
 	appstate.EnergyMax.Set(energyMaxValue)
 	appstate.Mood.Set(moodValue)
@@ -377,6 +502,8 @@ func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyV
 	appstate.Salary.Set(salary)
 	appstate.Working.Set(working)
 	appstate.Paused.Set(paused)
+	appstate.GameOver.Set(gameOver)
+	appstate.GameOverReason.Set(gameOverReason)
 	appstate.RoutineShower.Set(routineShower)
 	appstate.RoutineShave.Set(routineShave)
 	appstate.RoutineBrushTeeth.Set(routineBrushTeeth)
@@ -389,7 +516,12 @@ func NewAppState(ticksValue, workValue, workXP, foodValue, foodMaxValue, energyV
 	appstate.ChoiceEventChoices.Set(choiceEventChoices)
 	appstate.Events = GetEvents(&appstate)
 	appstate.Messages.Set(messages)
+	for _, warning := range skippedModWarnings(appstate.scriptErrors) {
+		appstate.Messages.Prepend(warning)
+	}
 	appstate.Variables.Set(variables)
+	appstate.scheduleEvents()
+	NewEventHandler(&appstate).resume()
 	return &appstate
 }
 
@@ -410,6 +542,8 @@ func NewAppStateWithDefaults() *AppState {
 		0,                // salary
 		false,            // working
 		false,            // paused
+		false,            // gameOver
+		"",               // gameOverReason
 		true,             // routineShower
 		false,            // routineShave
 		true,             // routineBrushTeeth
@@ -425,88 +559,209 @@ func NewAppStateWithDefaults() *AppState {
 	)
 }
 
-func fromJSON(jsonData string) *AppState {
+// Creates an AppState from save data, returns an error if the data can't
+// be read or has a value of the wrong type
+func fromJSON(jsonData string) (*AppState, error) {
 	var data map[string]any
 	err := json.Unmarshal([]byte(jsonData), &data)
 	if err != nil {
-		return nil
-	}
-	ticksValue := data["ticks"]
-	workValue := data["work"]
-	workXP := data["workXP"]
-	foodValue := data["food"]
-	foodMaxValue := data["foodMax"]
-	energyValue := data["energy"]
-	energyMaxValue := data["energyMax"]
-	moodValue := data["mood"]
-	moneyValue := data["money"]
-	charismaValue := data["charisma"]
-	fitnessValue := data["fitness"]
-	job := data["job"]
-	salary := data["salary"]
-	working := data["working"]
-	paused := data["paused"]
-	routineShower := data["routineShower"]
-	routineShave := data["routineShave"]
-	routineBrushTeeth := data["routineBrushTeeth"]
-	routineBonus := data["routineBonus"]
-	progressEventName := data["progressEventName"]
-	progressEventValue := data["progressEventValue"]
-	progressEventMax := data["progressEventMax"]
-	choiceEventName := data["choiceEventName"]
-	choiceEventText := data["choiceEventText"]
-	rawChoiceEventChoices := data["choiceEventChoices"]
-	rawMessages := data["messages"]
-	variables := data["variables"]
-
-	if ticksValue == nil || workValue == nil || workXP == nil || foodValue == nil || foodMaxValue == nil || energyValue == nil || energyMaxValue == nil || moodValue == nil || moneyValue == nil || charismaValue == nil || fitnessValue == nil || job == nil || salary == nil || working == nil || paused == nil || routineShower == nil || routineShave == nil || routineBrushTeeth == nil || routineBonus == nil || progressEventName == nil || progressEventValue == nil || progressEventMax == nil || choiceEventName == nil || choiceEventText == nil || rawChoiceEventChoices == nil || rawMessages == nil || variables == nil {
-		log.Panic("Error parsing JSON data")
-	}
-
-	choiceEventChoices := make([]string, 0)
-	for _, choice := range rawChoiceEventChoices.([]interface{}) {
-		choiceEventChoices = append(choiceEventChoices, choice.(string))
-	}
-
-	messages := make([]string, 0)
-	for _, msg := range rawMessages.([]interface{}) {
-		messages = append(messages, msg.(string))
+		return nil, err
+	}
+	err = migrateSave(data)
+	if err != nil {
+		return nil, fmt.Errorf("error migrating save: %w", err)
 	}
 
-	return NewAppState(
-		int(ticksValue.(float64)),
-		int(workValue.(float64)),
-		int(workXP.(float64)),
-		int(foodValue.(float64)),
-		int(foodMaxValue.(float64)),
-		int(energyValue.(float64)),
-		int(energyMaxValue.(float64)),
-		int(moodValue.(float64)),
-		int(charismaValue.(float64)),
-		int(moneyValue.(float64)),
-		int(fitnessValue.(float64)),
-		job.(string),
-		int(salary.(float64)),
-		working.(bool),
-		paused.(bool),
-		routineShower.(bool),
-		routineShave.(bool),
-		routineBrushTeeth.(bool),
-		int(routineBonus.(float64)),
-		progressEventName.(string),
-		int(progressEventValue.(float64)),
-		int(progressEventMax.(float64)),
-		choiceEventName.(string),
-		choiceEventText.(string),
+	// Read everything before creating the state, so a value with the wrong
+	// type is an error instead of a half loaded game
+	r := newSaveReader(data)
+	ticksValue := r.Int("ticks")
+	workValue := r.Int("work")
+	workXP := r.Int("workXP")
+	foodValue := r.Int("food")
+	foodMaxValue := r.Int("foodMax")
+	energyValue := r.Int("energy")
+	energyMaxValue := r.Int("energyMax")
+	moodValue := r.Int("mood")
+	moneyValue := r.Int("money")
+	charismaValue := r.Int("charisma")
+	fitnessValue := r.Int("fitness")
+	job := r.String("job")
+	salary := r.Int("salary")
+	working := r.Bool("working")
+	paused := r.Bool("paused")
+	gameOver := r.Bool("gameOver")
+	gameOverReason := r.String("gameOverReason")
+	routineShower := r.Bool("routineShower")
+	routineShave := r.Bool("routineShave")
+	routineBrushTeeth := r.Bool("routineBrushTeeth")
+	routineBonus := r.Int("routineBonus")
+	progressEventName := r.String("progressEventName")
+	progressEventValue := r.Int("progressEventValue")
+	progressEventMax := r.Int("progressEventMax")
+	choiceEventName := r.String("choiceEventName")
+	choiceEventText := r.String("choiceEventText")
+	choiceEventChoices := r.Strings("choiceEventChoices")
+	messages := r.Strings("messages")
+	variables := r.Map("variables")
+	doneEvents := r.Strings("doneEvents")
+	queuedEvents := r.Strings("queuedEvents")
+	savedAt := r.Int("savedAt")
+	rawSeed := r.String("seed")
+
+	buttons := r.StringMap("buttons")
+
+	var timers []Timer
+	for _, timer := range r.Objects("timers") {
+		timers = append(timers, Timer{timer.Int("tick"), timer.String("event"), timer.OptionalBool("scheduled")})
+	}
+
+	runs := map[string]EventRuns{}
+	for eventName, eventRuns := range r.ObjectMap("eventRuns") {
+		runs[eventName] = EventRuns{eventRuns.Int("count"), eventRuns.Int("lastTick")}
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	// JSON numbers are always decoded as float64, but scripts mostly work
+	// with ints and GameVariable arithmetic doesn't mix the two
+	for key, value := range variables {
+		if f, ok := value.(float64); ok && f == math.Trunc(f) {
+			variables[key] = int(f)
+		}
+	}
+
+	state := NewAppState(
+		ticksValue,
+		workValue,
+		workXP,
+		foodValue,
+		foodMaxValue,
+		energyValue,
+		energyMaxValue,
+		moodValue,
+		charismaValue,
+		moneyValue,
+		fitnessValue,
+		job,
+		salary,
+		working,
+		paused,
+		gameOver,
+		gameOverReason,
+		routineShower,
+		routineShave,
+		routineBrushTeeth,
+		routineBonus,
+		progressEventName,
+		progressEventValue,
+		progressEventMax,
+		choiceEventName,
+		choiceEventText,
 		choiceEventChoices,
 		messages,
-		variables.(map[string]any),
+		variables,
 	)
+
+	// Done events and buttons can only be restored once GetEvents has
+	// built the event list in NewAppState
+	state.SetDoneEvents(doneEvents)
+	state.SetButtons(buttons)
+
+	state.timers.SetTimers(timers)
+	state.scheduleEvents()
+	state.runLog.SetRuns(runs)
+	NewEventHandler(state).setQueue(queuedEvents)
+
+	// a seed of 0 keeps the new seed picked by NewAppState
+	seed, err := parseSeed(rawSeed)
+	if err != nil {
+		log.Println("Error reading seed, using a new one:", err)
+	} else if seed != 0 {
+		state.SetSeed(seed)
+	}
+
+	if savedAt > 0 {
+		state.savedAt = time.Unix(int64(savedAt), 0)
+	}
+
+	return state, nil
+}
+
+// Returns the status of all mods, including the ones that are disabled or
+// couldn't be loaded
+func (a *AppState) ModStatuses() []ModStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.mods)
+}
+
+// Replaces the current state with the state of other, keeping the existing
+// bindings so that the UI stays connected to this AppState
+func (a *AppState) replaceWith(other *AppState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	copyBinding(a.Ticks, other.Ticks)
+	copyBinding(a.Work, other.Work)
+	copyBinding(a.WorkXP, other.WorkXP)
+	copyBinding(a.Food, other.Food)
+	copyBinding(a.FoodMax, other.FoodMax)
+	copyBinding(a.EnergyMax, other.EnergyMax)
+	copyBinding(a.Energy, other.Energy)
+	copyBinding(a.Mood, other.Mood)
+	copyBinding(a.Money, other.Money)
+	copyBinding(a.Charisma, other.Charisma)
+	copyBinding(a.Fitness, other.Fitness)
+	copyBinding(a.Job, other.Job)
+	copyBinding(a.Salary, other.Salary)
+	copyBinding(a.Working, other.Working)
+	copyBinding(a.GameOverReason, other.GameOverReason)
+	copyBinding(a.GameOver, other.GameOver)
+	copyBinding(a.RoutineShower, other.RoutineShower)
+	copyBinding(a.RoutineShave, other.RoutineShave)
+	copyBinding(a.RoutineBrushTeeth, other.RoutineBrushTeeth)
+	copyBinding(a.RoutineBonus, other.RoutineBonus)
+	copyBinding(a.ProgressEventMax, other.ProgressEventMax)
+	copyBinding(a.ProgressEventValue, other.ProgressEventValue)
+	copyBinding(a.ProgressEventName, other.ProgressEventName)
+	copyBinding(a.ChoiceEventName, other.ChoiceEventName)
+	copyBinding(a.ChoiceEventText, other.ChoiceEventText)
+	copyBinding(a.ChoiceEventChoices, other.ChoiceEventChoices)
+	copyBinding(a.Messages, other.Messages)
+	a.SetButtons(other.GetButtons())
+	copyBinding(a.Variables, other.Variables)
+
+	// The events of other act on other, so they need to be rebuilt for
+	// this AppState
+	a.Events = GetEvents(a)
+	a.SetDoneEvents(other.DoneEvents())
+	a.timers.SetTimers(other.timers.Timers())
+	a.scheduleEvents()
+	a.SetSeed(other.random.Seed())
+	a.runLog.SetRuns(other.runLog.Runs())
+	NewEventHandler(a).resume()
+	NewEventHandler(a).setQueue(NewEventHandler(other).queuedNames())
+	copyBinding(a.Paused, other.Paused)
+}
+
+// Copies the value of one binding to another binding of the same type
+func copyBinding[T any](dst interface{ Set(T) error }, src interface{ Get() (T, error) }) {
+	v, err := src.Get()
+	if err != nil {
+		fmt.Println("Error copying binding:", err)
+		return
+	}
+	dst.Set(v)
 }
 
 // Processes a single tick in the game. In other game engines,
 // this would be like the update function called in a game loop
 func (state *AppState) gameTick() {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
 	// Pause
 	paused, err := state.Paused.Get()
 	if err != nil {
@@ -517,6 +772,16 @@ func (state *AppState) gameTick() {
 		return
 	}
 
+	// Game over
+	gameOver, err := state.GameOver.Get()
+	if err != nil {
+		fmt.Println("Error getting game over:", err)
+		return
+	}
+	if gameOver {
+		return
+	}
+
 	// Increment ticks
 	ticksValue, err := state.Ticks.Get()
 	if err != nil {
@@ -615,37 +880,47 @@ func (state *AppState) gameTick() {
 		}
 	} else {
 		if eventName != "Sleeping" {
-			fmt.Println("Game Over")
-			os.Exit(0)
+			state.SetGameOver("You ran out of food.")
+			return
 		}
 	}
 
-	// Process events in parallel
-
-	// Worker pool setup
-	numWorkers := runtime.NumCPU()
-	eventQueue := make(chan *Event, len(state.Events))
+	// Check the conditions of all events in parallel against a snapshot
+	// of the state, nothing changes the state while they are checked.
+	// Scheduled events are run by runTimers.
+	snapshot := state.Snapshot()
+	ready := make([]bool, len(state.Events))
+	eventQueue := make(chan int, len(state.Events))
 	var wg sync.WaitGroup
-
-	// Start worker goroutines
-	for range numWorkers {
+	for range runtime.NumCPU() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for event := range eventQueue {
-				state.handleEvent(event, false)
+			for i := range eventQueue {
+				ready[i] = state.checkEvent(&state.Events[i], snapshot, false)
 			}
 		}()
 	}
-
-	// Enqueue events
 	for i := range state.Events {
-		eventQueue <- &state.Events[i]
+		if state.Events[i].Schedule == nil {
+			eventQueue <- i
+		}
 	}
 	close(eventQueue)
-
 	wg.Wait()
 
+	// Then run the actions of the events whose conditions are true one
+	// after another in the order of state.Events, so events with a higher
+	// priority run first and every tick plays out the same way
+	for i := range state.Events {
+		if ready[i] {
+			state.runEvent(&state.Events[i])
+		}
+	}
+
+	// Run triggered events after all other events
+	state.runTimers(ticksValue)
+
 	// Handle current progress event (if there is one)
 	if eventName != "" {
 		eventValue, err := state.ProgressEventValue.Get()
@@ -654,24 +929,66 @@ func (state *AppState) gameTick() {
 			return
 		}
 		state.ProgressEventValue.Set(eventValue + 1)
+		NewEventHandler(state).advance()
 	}
 }
 
+// Checks the conditions of event against the current state and runs it
+// if they are true, or with ignoreCondition no matter what they are
 func (state *AppState) handleEvent(event *Event, ignoreCondition bool) {
+	if state.checkEvent(event, nil, ignoreCondition) {
+		state.runEvent(event)
+	}
+}
+
+// Reports whether event can run: it isn't done, its cooldown and max
+// allow it and its conditions are true when checked against snapshot, or
+// the AppState if snapshot is nil. Doesn't change the state, so events can
+// be checked in parallel.
+func (state *AppState) checkEvent(event *Event, snapshot Variables, ignoreCondition bool) bool {
 	if event.Done {
-		return
+		return false
 	}
-	if !ignoreCondition && !event.Condition() {
-		return
+	tick, err := state.Ticks.Get()
+	if err != nil {
+		fmt.Println("Error getting ticks:", err)
+		return false
+	}
+	if !state.runLog.Allowed(event, tick) {
+		return false
 	}
+	if event.Start != nil {
+		event.Start(snapshot)
+	}
+	return ignoreCondition || event.Condition()
+}
+
+// Runs the actions of an event that passed checkEvent
+func (state *AppState) runEvent(event *Event) {
+	if event.Cooldown > 0 || event.MaxRuns > 0 {
+		tick, err := state.Ticks.Get()
+		if err != nil {
+			fmt.Println("Error getting ticks:", err)
+			return
+		}
+		state.runLog.Add(event.Name, tick)
+	}
+	if state.eventHook != nil {
+		state.eventHook(event)
+	}
+	state.scheduleAfter(event)
 	if len(event.Choices) > 0 {
+		var vars Variables = state
+		if event.Variables != nil {
+			vars = event.Variables
+		}
 		keys := make([]string, 0, len(event.Choices)) // Preallocate slice with capacity
-		for key, value := range event.Choices {
+		for _, value := range orderedChoices(event.Choices) {
 			if value.Conditions != nil {
 				// check if all conditions return true
 				allTrue := true
 				for _, condition := range value.Conditions {
-					conditionFn := scriptConditionToFn(state, condition)
+					conditionFn := scriptConditionToFn(vars, condition)
 					if !conditionFn() {
 						allTrue = false
 						break
@@ -681,7 +998,7 @@ func (state *AppState) handleEvent(event *Event, ignoreCondition bool) {
 					continue
 				}
 			}
-			keys = append(keys, key)
+			keys = append(keys, value.ButtonText)
 		}
 		state.ChoiceEventChoices.Set(keys)
 		state.ChoiceEventName.Set(event.Name)
@@ -691,7 +1008,88 @@ func (state *AppState) handleEvent(event *Event, ignoreCondition bool) {
 	}
 }
 
+// Runs the event of a button in the UI, no matter what its conditions are
+func (state *AppState) ClickButton(eventName string) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	event := state.GetEvent(eventName)
+	if event == nil {
+		fmt.Println("Error running button:", fmt.Errorf("event not found: '%s'", eventName))
+		return
+	}
+	state.handleEvent(event, true)
+}
+
+// Cancels the running or a queued progress event, the actions of a
+// cancelled event don't run
+func (state *AppState) CancelProgressEvent(eventName string) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !NewEventHandler(state).cancel(eventName) {
+		fmt.Println("Error cancelling event:", fmt.Errorf("event not running or queued: '%s'", eventName))
+	}
+}
+
+// Reason shown when a script sets gameOver to true
+const DefaultGameOverReason = "Game over"
+
+// Ends the game, no more ticks are processed until a new game is started
+func (state *AppState) SetGameOver(reason string) {
+	state.GameOverReason.Set(reason)
+	state.GameOver.Set(true)
+}
+
+// Buys up to units portions of 100 food for $100 each, as many as the
+// player can afford. Returns the number of portions bought.
+func (state *AppState) BuyFood(units int) int {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	money, err := state.Money.Get()
+	if err != nil {
+		fmt.Println("Error getting money:", err)
+		return 0
+	}
+	food, err := state.Food.Get()
+	if err != nil {
+		fmt.Println("Error getting food:", err)
+		return 0
+	}
+	ableToPurchase := min(money/100, units)
+	if ableToPurchase <= 0 {
+		return 0
+	}
+	state.Money.Set(money - ableToPurchase*100)
+	state.Food.Set(food + ableToPurchase*100)
+	state.FoodMax.Set(food + ableToPurchase*100)
+	return ableToPurchase
+}
+
+// Picks one of the choices of the current choice event and runs the event
+// the choice links to
+func (state *AppState) Choose(choice string) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	currentEventName, err := state.ChoiceEventName.Get()
+	if err != nil {
+		return err
+	}
+	currentEvent := state.GetEvent(currentEventName)
+	if currentEvent == nil {
+		return fmt.Errorf("event not found: '%s'", currentEventName)
+	}
+	state.ChoiceEventChoices.Set([]string{})
+	state.ChoiceEventName.Set("")
+	event := state.GetEvent(currentEvent.Choices[choice].EventName)
+	if event == nil {
+		return fmt.Errorf("event not found: '%s'", currentEvent.Choices[choice].EventName)
+	}
+	state.handleEvent(event, true)
+	return nil
+}
+
 func (state *AppState) toJSON() (string, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
 	ticksValue, err := state.Ticks.Get()
 	if err != nil {
 		return "", err
@@ -752,6 +1150,14 @@ func (state *AppState) toJSON() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	gameOver, err := state.GameOver.Get()
+	if err != nil {
+		return "", err
+	}
+	gameOverReason, err := state.GameOverReason.Get()
+	if err != nil {
+		return "", err
+	}
 	routineShower, err := state.RoutineShower.Get()
 	if err != nil {
 		return "", err
@@ -801,6 +1207,14 @@ func (state *AppState) toJSON() (string, error) {
 		return "", err
 	}
 	jsonData, err := json.Marshal(map[string]any{
+		"version":            saveVersion,
+		"savedAt":            time.Now().Unix(),
+		"doneEvents":         state.DoneEvents(),
+		"buttons":            state.GetButtons(),
+		"timers":             state.timers.Timers(),
+		"seed":               formatSeed(state.random.Seed()),
+		"eventRuns":          state.runLog.Runs(),
+		"queuedEvents":       NewEventHandler(state).queuedNames(),
 		"ticks":              ticksValue,
 		"work":               workValue,
 		"workXP":             workXP,
@@ -816,6 +1230,8 @@ func (state *AppState) toJSON() (string, error) {
 		"salary":             salary,
 		"working":            working,
 		"paused":             paused,
+		"gameOver":           gameOver,
+		"gameOverReason":     gameOverReason,
 		"routineShower":      routineShower,
 		"routineShave":       routineShave,
 		"routineBrushTeeth":  routineBrushTeeth,
@@ -0,0 +1,182 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// -----------------------------
+// Tests for game over
+// -----------------------------
+
+func TestGameTickGameOver(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.Events = []Event{}
+	state.Food.Set(0)
+
+	state.gameTick()
+	checkBindingBool(t, state.GameOver, true)
+	checkBindingString(t, state.GameOverReason, "You ran out of food.")
+
+	// no more ticks are processed once the game is over
+	ticks := state.Get("ticks")
+	state.gameTick()
+	if state.Get("ticks") != ticks {
+		t.Errorf("Expected ticks to stay at %v, got %v", ticks, state.Get("ticks"))
+	}
+}
+
+func TestScriptedGameOver(t *testing.T) {
+	state := NewAppStateWithDefaults()
+
+	modifyState(state, "gameOver", "=", "You were eaten by a grue.")
+	if state.Get("gameOver") != true {
+		t.Errorf("Expected gameOver to be true")
+	}
+	checkBindingString(t, state.GameOverReason, "You were eaten by a grue.")
+
+	modifyState(state, "gameOver", "=", false)
+	checkBindingBool(t, state.GameOver, false)
+
+	modifyState(state, "gameOver", "=", true)
+	checkBindingString(t, state.GameOverReason, DefaultGameOverReason)
+}
+
+// -----------------------------
+// Tests for event order
+// -----------------------------
+
+func TestParsePriority(t *testing.T) {
+	scriptEvents, err := parseScript("=== Late\npriority -5\n? true\n\n=== Broken\npriority soon")
+	if scriptEvents[0].Priority != -5 {
+		t.Errorf("Expected priority -5, got %d", scriptEvents[0].Priority)
+	}
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok || len(scriptErrors) != 1 || scriptErrors[0].Message != "priority must be a whole number: soon" || scriptErrors[0].Column != 10 {
+		t.Errorf("Expected one error for priority soon at column 10, got %v", err)
+	}
+}
+
+func TestEventPriorityOrder(t *testing.T) {
+	state := newScriptedState(t, `=== A
+? true
+! order = order + "A"
+
+=== B
+? true
+priority 10
+! order = order + "B"
+
+=== C
+? true
+priority -5
+! order = order + "C"
+
+=== D
+? true
+priority 10
+! order = order + "D"`)
+	state.Set("order", "")
+
+	state.gameTick()
+	state.gameTick()
+
+	// higher priority first, same priority in the order of the script
+	if order := state.Get("order"); order != "BDACBDAC" {
+		t.Errorf("Expected events to run in the order BDAC, got %v", order)
+	}
+}
+
+// -----------------------------
+// Tests for concurrent events, run with go test -race
+// -----------------------------
+
+func TestEventsDontLoseUpdates(t *testing.T) {
+	var script strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&script, "=== Payment %d\n? true\n! money += 10\n! bonus += 1\n\n", i)
+	}
+	state := newScriptedState(t, script.String())
+	state.Money.Set(0)
+	state.Set("bonus", 0)
+
+	state.gameTick()
+
+	checkBindingInt(t, state.Money, 2000)
+	if bonus := state.Get("bonus"); bonus != 200 {
+		t.Errorf("Expected bonus to be 200, got %v", bonus)
+	}
+}
+
+func TestConditionsSeeSnapshot(t *testing.T) {
+	state := newScriptedState(t, `=== Spend
+? money >= 100
+priority 1
+! money -= 100
+
+=== Also spend
+? money >= 100
+! money -= 100`)
+	state.Money.Set(100)
+
+	state.gameTick()
+
+	// both conditions were true at the start of the tick, so both events
+	// ran even though the first one spent the money
+	checkBindingInt(t, state.Money, -100)
+}
+
+func TestPlayerActionsDuringTicks(t *testing.T) {
+	state := newScriptedState(t, `=== Earn
+? true
+! money += 100
+! earned += 1
+
+=== Spend
+? money > 50
+? rand < 0.5
+! money -= 50
+
+=== Button
+! clicks += 1`)
+	state.Money.Set(0)
+	state.Set("earned", 0)
+	state.Set("clicks", 0)
+	state.AddButton("Click", "Button")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			state.gameTick()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			state.ClickButton("Button")
+			state.BuyFood(1)
+			if _, err := state.toJSON(); err != nil {
+				t.Errorf("Error converting AppState to JSON: %s", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if earned := state.Get("earned"); earned != 100 {
+		t.Errorf("Expected 100 earnings, got %v", earned)
+	}
+	if clicks := state.Get("clicks"); clicks != 100 {
+		t.Errorf("Expected 100 clicks, got %v", clicks)
+	}
+}
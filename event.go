@@ -7,12 +7,37 @@
 
 package main
 
+import (
+	"cmp"
+	"log"
+	"slices"
+)
+
 type Event struct {
 	Name      string
 	Done      bool
 	Condition func() bool
 	Action    func() bool
 	Choices   map[string]Choice
+	// Runs the actions of a progress event once it is finished, used to
+	// resume progress events from a save
+	OnProgressDone func()
+	ProgressMax    int
+	// Scheduled events run from the timer queue instead of being checked
+	// on every tick
+	Schedule *ScriptSchedule
+	// Called every time before the event is handled, rolls the random
+	// numbers of the event and sets the snapshot its conditions are
+	// checked against, nil to check them against the AppState
+	Start func(snapshot Variables)
+	// Variables seen by the conditions of the choices, the AppState if nil
+	Variables Variables
+	// Minimum number of ticks between two runs and maximum number of
+	// runs, 0 for no limit
+	Cooldown int
+	MaxRuns  int
+	// Events with a higher priority are checked first on every tick
+	Priority int
 }
 
 // Creates a new Event which is displayed in the eventContainer
@@ -32,9 +57,18 @@ func NewEvent(name string, condition func() bool, action func() bool, choices ma
 func GetEvents(appstate *AppState) []Event {
 	var events []Event
 
-	script := readScript()
-	scriptEvents := parseScript(script)
-	for _, scriptEvent := range scriptEvents {
+	mods, scriptEvents, errs := readScript()
+	for _, mod := range mods {
+		if mod.Loaded {
+			log.Printf("Loaded mod %s\n", mod.Mod)
+		}
+	}
+	for _, err := range errs {
+		log.Printf("Error in mod %s: %v\n", err.Mod, err)
+	}
+	appstate.mods = mods
+	appstate.scriptErrors = errs
+	for _, scriptEvent := range applyOverrides(scriptEvents) {
 		event := scriptEventToEvent(appstate, scriptEvent)
 		events = append(events, event)
 	}
@@ -42,5 +76,14 @@ func GetEvents(appstate *AppState) []Event {
 	otherEvents := []Event{}
 
 	events = append(events, otherEvents...)
+	sortEvents(events)
 	return events
 }
+
+// Sorts events into the order they are checked in on every tick, by
+// priority and then by the order they were loaded in
+func sortEvents(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+}
@@ -0,0 +1,610 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Expressions in conditions and actions
+
+? money > salary * 3
+! food += foodMax / 10
+! mood = (mood + fitness) / 2
+! job = "Sales clerk"
+? (mood < 10 or energy < 5) and not working
+
+Expressions support + - * / with the usual precedence, parentheses,
+numbers, "quoted strings", true, false and variables. A bare word that
+is not a variable evaluates to its own name, so "? job == Manager" keeps
+working without quotes, and a value made of several words like
+"! job = Sales clerk" is a string. A - between two letters is part of the
+word, to subtract put spaces around it. Ints are turned into floats when
+they are mixed with floats.
+
+Conditions can also compare values with == != < > <= >= and combine them
+with and, or, not and parentheses. "not" binds tighter than "and", which
+binds tighter than "or".
+*/
+
+// -----------------------------
+// Expression Types
+// -----------------------------
+
+// Variables are what expressions are evaluated against, usually the
+// AppState
+type Variables interface {
+	Get(variable string) interface{}
+}
+
+// Expr is a parsed expression that is evaluated against the AppState
+// every time a condition is checked or an action runs
+type Expr interface {
+	Eval(vars Variables) interface{}
+	String() string
+}
+
+// A number, string or boolean written in the script
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) Eval(vars Variables) interface{} {
+	return e.value
+}
+
+func (e literalExpr) String() string {
+	if s, ok := e.value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(e.value)
+}
+
+// A reference to a built-in or custom variable
+type variableExpr struct {
+	name string
+}
+
+func (e variableExpr) Eval(vars Variables) interface{} {
+	value := vars.Get(e.name)
+	if value == nil {
+		// not a variable, so it's a bare word
+		return e.name
+	}
+	return value
+}
+
+func (e variableExpr) String() string {
+	return e.name
+}
+
+// A negated expression
+type negateExpr struct {
+	operand Expr
+}
+
+func (e negateExpr) Eval(vars Variables) interface{} {
+	operand := NewGameVariable("", e.operand.Eval(vars))
+	switch operand.value.(type) {
+	case float64:
+		return NewGameVariable("", 0.0).Subtract(operand).value
+	default:
+		return NewGameVariable("", 0).Subtract(operand).value
+	}
+}
+
+func (e negateExpr) String() string {
+	return fmt.Sprintf("-%s", e.operand)
+}
+
+// An arithmetic operation on two expressions
+type binaryExpr struct {
+	operator    string
+	left, right Expr
+}
+
+func (e binaryExpr) Eval(vars Variables) interface{} {
+	left := NewGameVariable("", e.left.Eval(vars))
+	right := NewGameVariable("", e.right.Eval(vars))
+	return left.Apply(e.operator, right).value
+}
+
+func (e binaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.left, e.operator, e.right)
+}
+
+// A comparison of two expressions, evaluates to a bool
+type compareExpr struct {
+	operator    string
+	left, right Expr
+}
+
+func (e compareExpr) Eval(vars Variables) interface{} {
+	left := NewGameVariable("", e.left.Eval(vars))
+	right := NewGameVariable("", e.right.Eval(vars))
+	return left.Compare(right, e.operator)
+}
+
+func (e compareExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.left, e.operator, e.right)
+}
+
+// Two conditions combined with "and" or "or"
+type logicExpr struct {
+	operator    string
+	left, right Expr
+}
+
+func (e logicExpr) Eval(vars Variables) interface{} {
+	left := NewGameVariable("", e.left.Eval(vars)).Bool()
+	if e.operator == "and" && !left || e.operator == "or" && left {
+		return left
+	}
+	return NewGameVariable("", e.right.Eval(vars)).Bool()
+}
+
+func (e logicExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.left, e.operator, e.right)
+}
+
+// A negated condition
+type notExpr struct {
+	operand Expr
+}
+
+func (e notExpr) Eval(vars Variables) interface{} {
+	return !NewGameVariable("", e.operand.Eval(vars)).Bool()
+}
+
+func (e notExpr) String() string {
+	return fmt.Sprintf("not %s", e.operand)
+}
+
+// Returns the names of the variables used in arithmetic in expr. A bare word
+// there is almost always a misspelled variable, because text can't be
+// calculated with.
+func arithmeticVariables(expr Expr) []string {
+	var names []string
+	var walk func(expr Expr, inArithmetic bool)
+	walk = func(expr Expr, inArithmetic bool) {
+		switch e := expr.(type) {
+		case variableExpr:
+			if inArithmetic {
+				names = append(names, e.name)
+			}
+		case negateExpr:
+			walk(e.operand, true)
+		case binaryExpr:
+			walk(e.left, true)
+			walk(e.right, true)
+		case compareExpr:
+			walk(e.left, false)
+			walk(e.right, false)
+		case logicExpr:
+			walk(e.left, false)
+			walk(e.right, false)
+		case notExpr:
+			walk(e.operand, false)
+		}
+	}
+	walk(expr, false)
+	return names
+}
+
+// -----------------------------
+// Tokenizer
+// -----------------------------
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenString
+	tokenWord
+	tokenOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+// Operators in the order they are matched, longer ones first
+var expressionOperators = []string{
+	"==", "!=", "<=", ">=", "+=", "-=", "*=", "/=",
+	"<", ">", "=", "+", "-", "*", "/", "(", ")",
+}
+
+// Characters that end a word
+const operatorChars = "=!<>+-*/()\""
+
+// Splits s into tokens, columns start at 1
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+outer:
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, s[start:i], start + 1})
+
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, newSyntaxError(i+1, "missing closing quote")
+			}
+			tokens = append(tokens, token{tokenString, s[i+1 : i+1+end], i + 1})
+			i += end + 2
+
+		case strings.IndexByte(operatorChars, c) >= 0:
+			for _, operator := range expressionOperators {
+				if strings.HasPrefix(s[i:], operator) {
+					tokens = append(tokens, token{tokenOperator, operator, i + 1})
+					i += len(operator)
+					continue outer
+				}
+			}
+			return nil, newSyntaxError(i+1, "unexpected %c", c)
+
+		default:
+			start := i
+			for i < len(s) && !isWordEnd(s, i) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, s[start:i], start + 1})
+		}
+	}
+	return tokens, nil
+}
+
+// Reports whether the word ends at s[i]. A - between two letters is part
+// of the word like in "Part-time", subtracting needs spaces around the -.
+func isWordEnd(s string, i int) bool {
+	c := s[i]
+	if c == ' ' || c == '\t' {
+		return true
+	}
+	if c == '-' && i+1 < len(s) {
+		next := s[i+1]
+		return next == ' ' || next == '\t' || next >= '0' && next <= '9' || strings.IndexByte(operatorChars, next) >= 0
+	}
+	return strings.IndexByte(operatorChars, c) >= 0
+}
+
+// -----------------------------
+// Parser
+// -----------------------------
+
+// Recursive descent parser for expressions:
+//
+//	condition   = conjunction { "or" conjunction }
+//	conjunction = negation { "and" negation }
+//	negation    = "not" negation | comparison
+//	comparison  = expression [ ("==" | "!=" | "<" | ">" | "<=" | ">=") expression ]
+//	expression  = term { ("+" | "-") term }
+//	term        = unary { ("*" | "/") unary }
+//	unary       = "-" unary | primary
+//	primary     = number | string | word | "(" condition ")"
+type expressionParser struct {
+	tokens []token
+	pos    int
+	// column after the last token, used for errors at the end of the line
+	end int
+}
+
+func newExpressionParser(tokens []token, end int) *expressionParser {
+	return &expressionParser{tokens: tokens, end: end}
+}
+
+// Returns the current token, or nil at the end of the line
+func (p *expressionParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// Returns the current token if it is one of the given operators
+func (p *expressionParser) acceptOperator(operators ...string) *token {
+	t := p.peek()
+	if t != nil && t.kind == tokenOperator {
+		for _, operator := range operators {
+			if t.text == operator {
+				p.pos++
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the current token if it is the given keyword
+func (p *expressionParser) acceptKeyword(keyword string) *token {
+	t := p.peek()
+	if t != nil && t.kind == tokenWord && t.text == keyword {
+		p.pos++
+		return t
+	}
+	return nil
+}
+
+// Returns an error for the current token
+func (p *expressionParser) unexpected() error {
+	t := p.peek()
+	if t == nil {
+		return newSyntaxError(p.end, "expression is incomplete")
+	}
+	return newSyntaxError(t.column, "unexpected %s", t.text)
+}
+
+func (p *expressionParser) condition() (Expr, error) {
+	left, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") != nil {
+		right, err := p.conjunction()
+		if err != nil {
+			return nil, err
+		}
+		left = logicExpr{"or", left, right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) conjunction() (Expr, error) {
+	left, err := p.negation()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") != nil {
+		right, err := p.negation()
+		if err != nil {
+			return nil, err
+		}
+		left = logicExpr{"and", left, right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) negation() (Expr, error) {
+	if p.acceptKeyword("not") != nil {
+		operand, err := p.negation()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	}
+	return p.comparison()
+}
+
+func (p *expressionParser) comparison() (Expr, error) {
+	left, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	t := p.acceptOperator(conditionOperators...)
+	if t == nil {
+		return left, nil
+	}
+
+	start := p.pos
+	right, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	// Plain words after the operator are text, like in
+	// "eventName == Morning Routine"
+	if p.pos == start+1 && p.isPlainWord(start) && p.isPlainWord(p.pos) {
+		for p.isPlainWord(p.pos) {
+			p.pos++
+		}
+		text, _ := plainText(p.tokens[start:p.pos])
+		right = literalExpr{text}
+	}
+	return compareExpr{t.text, left, right}, nil
+}
+
+// Reports whether tokens[i] is a word or number that isn't a keyword
+func (p *expressionParser) isPlainWord(i int) bool {
+	if i >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[i]
+	switch t.kind {
+	case tokenNumber:
+		return true
+	case tokenWord:
+		return t.text != "and" && t.text != "or" && t.text != "not"
+	}
+	return false
+}
+
+func (p *expressionParser) expression() (Expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.acceptOperator("+", "-")
+		if t == nil {
+			return left, nil
+		}
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{t.text, left, right}
+	}
+}
+
+func (p *expressionParser) term() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.acceptOperator("*", "/")
+		if t == nil {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{t.text, left, right}
+	}
+}
+
+func (p *expressionParser) unary() (Expr, error) {
+	if p.acceptOperator("-") != nil {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		// fold negative numbers into the literal
+		if literal, ok := operand.(literalExpr); ok {
+			switch v := literal.value.(type) {
+			case int:
+				return literalExpr{-v}, nil
+			case float64:
+				return literalExpr{-v}, nil
+			}
+		}
+		return negateExpr{operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *expressionParser) primary() (Expr, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.unexpected()
+	}
+
+	switch t.kind {
+	case tokenNumber:
+		p.pos++
+		if i, err := strconv.Atoi(t.text); err == nil {
+			return literalExpr{i}, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return literalExpr{f}, nil
+		}
+		return nil, newSyntaxError(t.column, "invalid number: %s", t.text)
+
+	case tokenString:
+		p.pos++
+		return literalExpr{t.text}, nil
+
+	case tokenWord:
+		p.pos++
+		switch t.text {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		}
+		return variableExpr{t.text}, nil
+	}
+
+	if p.acceptOperator("(") != nil {
+		expr, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		if p.acceptOperator(")") == nil {
+			if p.peek() == nil {
+				return nil, newSyntaxError(t.column, "missing closing parenthesis")
+			}
+			return nil, p.unexpected()
+		}
+		return expr, nil
+	}
+	return nil, p.unexpected()
+}
+
+// Parses tokens into a single expression, end is the column after the last
+// token
+func parseTokens(tokens []token, end int) (Expr, error) {
+	p := newExpressionParser(tokens, end)
+	expr, err := p.expression()
+	if err == nil && p.peek() != nil {
+		err = p.unexpected()
+	}
+	return expr, err
+}
+
+// Parses tokens into a single condition, end is the column after the last
+// token
+func parseConditionTokens(tokens []token, end int) (Expr, error) {
+	p := newExpressionParser(tokens, end)
+	expr, err := p.condition()
+	if err == nil && p.peek() != nil {
+		err = p.unexpected()
+	}
+	return expr, err
+}
+
+// Like parseTokens, but if the tokens are only plain words like in
+// "Morning Routine" they are turned into a string, so text values don't
+// need quotes
+func parseExpressionTokens(tokens []token, end int) (Expr, error) {
+	expr, err := parseTokens(tokens, end)
+	if err != nil {
+		if text, ok := plainText(tokens); ok {
+			return literalExpr{text}, nil
+		}
+		return nil, err
+	}
+	return expr, nil
+}
+
+// Parses a single expression
+func parseExpression(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	return parseExpressionTokens(tokens, len(s)+1)
+}
+
+// Evaluates value if it is an Expr, other values are returned as they are
+func evalValue(vars Variables, value interface{}) interface{} {
+	if expr, ok := value.(Expr); ok {
+		return expr.Eval(vars)
+	}
+	return value
+}
+
+// Joins the tokens with a space if they are only words and numbers
+func plainText(tokens []token) (string, bool) {
+	if len(tokens) == 0 {
+		return "", false
+	}
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if t.kind != tokenWord && t.kind != tokenNumber {
+			return "", false
+		}
+		words = append(words, t.text)
+	}
+	return strings.Join(words, " "), true
+}
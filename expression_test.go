@@ -0,0 +1,240 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"testing"
+)
+
+// -----------------------------
+// Tests for expressions
+// -----------------------------
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"(mood + fitness) / 2", "((mood + fitness) / 2)"},
+		{"10 - 2 - 3", "((10 - 2) - 3)"},
+		{"-mood * 2", "(-mood * 2)"},
+		{"foodMax-10", "(foodMax - 10)"},
+		{`"Sales clerk"`, `"Sales clerk"`},
+		{"Sales clerk", `"Sales clerk"`},
+	}
+
+	for _, test := range tests {
+		expr, err := parseExpression(test.input)
+		if err != nil {
+			t.Errorf("parseExpression(%q) returned error: %s", test.input, err)
+			continue
+		}
+		if expr.String() != test.expected {
+			t.Errorf("parseExpression(%q) = %s, expected %s", test.input, expr, test.expected)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		column  int
+		message string
+	}{
+		{"mood +", 7, "expression is incomplete"},
+		{"(mood + 1", 1, "missing closing parenthesis"},
+		{"mood + 1)", 9, "unexpected )"},
+		{`"unfinished`, 1, "missing closing quote"},
+		{"1 * * 2", 5, "unexpected *"},
+	}
+
+	for _, test := range tests {
+		_, err := parseExpression(test.input)
+		se, ok := err.(*syntaxError)
+		if !ok {
+			t.Errorf("parseExpression(%q) = %v, expected a syntax error", test.input, err)
+			continue
+		}
+		if se.column != test.column || se.message != test.message {
+			t.Errorf("parseExpression(%q) error = %d: %s, expected %d: %s", test.input, se.column, se.message, test.column, test.message)
+		}
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.Mood.Set(40)
+	state.Fitness.Set(20)
+	state.FoodMax.Set(200)
+	state.Set("bonus", 1.5)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"(mood + fitness) / 2", 30},
+		{"foodMax / 10", 20},
+		{"mood * bonus", 60.0},
+		{"-fitness", -20},
+		{"Manager", "Manager"},
+		{`"Sales " + "clerk"`, "Sales clerk"},
+	}
+
+	for _, test := range tests {
+		expr, err := parseExpression(test.input)
+		if err != nil {
+			t.Errorf("parseExpression(%q) returned error: %s", test.input, err)
+			continue
+		}
+		if result := expr.Eval(state); result != test.expected {
+			t.Errorf("%s = %v, expected %v", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestExpressionActions(t *testing.T) {
+	script := `=== Eat
+? money > salary * 3
+! food += foodMax / 10
+! mood = (mood + fitness) / 2
+! energy += energyMax * 0.1
+> true`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	state := NewAppStateWithDefaults()
+	state.Money.Set(100)
+	state.Salary.Set(50)
+	state.Food.Set(0)
+	state.FoodMax.Set(200)
+	state.Mood.Set(40)
+	state.Fitness.Set(20)
+	state.Energy.Set(10)
+	state.EnergyMax.Set(100)
+
+	event := scriptEventToEvent(state, scriptEvents[0])
+	if event.Condition() {
+		t.Errorf("Expected condition to be false with money 100 and salary 50")
+	}
+	state.Money.Set(151)
+	if !event.Condition() {
+		t.Errorf("Expected condition to be true with money 151 and salary 50")
+	}
+
+	event.Action()
+	if food := state.Get("food"); food != 20 {
+		t.Errorf("Expected food to be 20, got %v", food)
+	}
+	if mood := state.Get("mood"); mood != 30 {
+		t.Errorf("Expected mood to be 30, got %v", mood)
+	}
+	if energy := state.Get("energy"); energy != 20 {
+		t.Errorf("Expected energy to stay an int and be 20, got %v", energy)
+	}
+}
+
+func TestAssignWrongTypes(t *testing.T) {
+	state := newScriptedState(t, `=== Assign
+! food = foodMax * 0.5
+! money = salry * 2
+! ratio = 0.5
+> true`)
+	state.Set("ratio", 0)
+	state.Food.Set(50)
+	state.FoodMax.Set(200)
+	state.Money.Set(100)
+
+	// must not panic
+	state.Trigger("Assign", 0)
+	state.gameTick()
+
+	if food := state.Get("food"); food != 100 {
+		t.Errorf("Expected food to be set to the whole number 100, got %v (%T)", food, food)
+	}
+	if money := state.Get("money"); money != 100 {
+		t.Errorf("Expected money to stay 100 when set to text, got %v", money)
+	}
+	if ratio := state.Get("ratio"); ratio != 0.5 {
+		t.Errorf("Expected a custom variable to take the type of the value, got %v", ratio)
+	}
+}
+
+func TestSetWrongType(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	tests := []struct {
+		variable string
+		value    interface{}
+	}{
+		{"money", 1.5},
+		{"money", "salry"},
+		{"job", 3},
+		{"working", "yes"},
+		{"gameOver", 1},
+	}
+	for _, test := range tests {
+		if err := state.Set(test.variable, test.value); err == nil {
+			t.Errorf("Expected an error setting %s to %v", test.variable, test.value)
+		}
+	}
+	if err := state.Set("money", 5); err != nil {
+		t.Errorf("Expected no error setting money to 5, got %s", err)
+	}
+}
+
+func TestEvalCondition(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.Mood.Set(50)
+	state.Energy.Set(3)
+	state.Working.Set(false)
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"(mood < 10 or energy < 5) and not working", true},
+		{"mood < 10 or energy < 5 and working", false},
+		{"not (mood > 10 and energy > 10)", true},
+		{"not not working", false},
+		{"mood == 50 and job == \"\"", true},
+	}
+
+	for _, test := range tests {
+		condition, err := parseCondition(test.input)
+		if err != nil {
+			t.Errorf("parseCondition(%q) returned error: %s", test.input, err)
+			continue
+		}
+		if result := scriptConditionToFn(state, condition)(); result != test.expected {
+			t.Errorf("%s = %v, expected %v", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestChoiceConditionsWithOr(t *testing.T) {
+	_, _, conditions, err := parseChoice(" mood > 90 or energy < 5, not working: Rest -> Resting")
+	if err != nil {
+		t.Fatalf("Error parsing choice: %s", err)
+	}
+	if len(conditions) != 2 {
+		t.Fatalf("Expected 2 conditions, got %d", len(conditions))
+	}
+
+	state := NewAppStateWithDefaults()
+	state.Mood.Set(50)
+	state.Energy.Set(3)
+	for _, condition := range conditions {
+		if !scriptConditionToFn(state, condition)() {
+			t.Errorf("Expected %s to be true", condition.Expr)
+		}
+	}
+}
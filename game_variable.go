@@ -115,6 +115,38 @@ func (gv GameVariable) Divide(other GameVariable) GameVariable {
 	return gv
 }
 
+// Applies an arithmetic operator (+ - * /) to two GameVariables
+func (gv GameVariable) Apply(operator string, other GameVariable) GameVariable {
+	gv, other = gv.promote(other)
+	switch operator {
+	case "+":
+		return gv.Add(other)
+	case "-":
+		return gv.Subtract(other)
+	case "*":
+		return gv.Multiply(other)
+	case "/":
+		return gv.Divide(other)
+	}
+	return gv
+}
+
+// Turns an int into a float64 if the other GameVariable is a float64,
+// so ints and floats can be mixed in calculations and comparisons
+func (gv GameVariable) promote(other GameVariable) (GameVariable, GameVariable) {
+	switch v := gv.value.(type) {
+	case int:
+		if _, ok := other.value.(float64); ok {
+			return GameVariable{gv.name, float64(v)}, other
+		}
+	case float64:
+		if ov, ok := other.value.(int); ok {
+			return gv, GameVariable{other.name, float64(ov)}
+		}
+	}
+	return gv, other
+}
+
 // ------------------------------
 // Comparison
 // ------------------------------
@@ -171,6 +203,7 @@ func (gv GameVariable) GreaterThan(other GameVariable) bool {
 
 // Compare two GameVariables
 func (gv GameVariable) Compare(other GameVariable, operator string) bool {
+	gv, other = gv.promote(other)
 	switch operator {
 	case "==":
 		return gv.value == other.value
@@ -236,6 +269,6 @@ func (gv GameVariable) Float64() float64 {
 // AppState
 // ------------------------------
 
-func (gv GameVariable) UpdateAppState(state *AppState) {
-	state.Set(gv.name, gv.value)
+func (gv GameVariable) UpdateAppState(state *AppState) error {
+	return state.Set(gv.name, gv.value)
 }
@@ -0,0 +1,144 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Time to wait after a change in the mods folder before reloading, editors
+// often write a file in several steps
+const ModReloadDelay = 200 * time.Millisecond
+
+// ModWatcher reloads the events of an AppState when a script, manifest or
+// the mod config in the mods folder changes
+type ModWatcher struct {
+	watcher *fsnotify.Watcher
+	state   *AppState
+	modPath string
+	delay   time.Duration
+	timer   *time.Timer
+}
+
+// Starts watching the mods folder at modPath, the events of state are
+// reloaded delay after the last change
+func NewModWatcher(state *AppState, modPath string, delay time.Duration) (*ModWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &ModWatcher{watcher: watcher, state: state, modPath: modPath, delay: delay}
+	w.timer = time.AfterFunc(delay, w.reload)
+	w.timer.Stop()
+	w.watchDirs()
+	go w.run()
+	return w, nil
+}
+
+// Stops watching the mods folder
+func (w *ModWatcher) Close() error {
+	w.timer.Stop()
+	return w.watcher.Close()
+}
+
+// Watches the mods folder, the folder of every mod and its scripts folder.
+// fsnotify doesn't watch subfolders, so this is repeated when folders are
+// added.
+func (w *ModWatcher) watchDirs() {
+	dirs := []string{w.modPath, filepath.Join(w.modPath, "scripts")}
+	entries, err := os.ReadDir(w.modPath)
+	if err != nil {
+		fmt.Println("Error reading mods folder:", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dir := filepath.Join(w.modPath, entry.Name())
+			dirs = append(dirs, dir, filepath.Join(dir, "scripts"))
+		}
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			fmt.Println("Error watching mods folder:", err)
+		}
+	}
+}
+
+func (w *ModWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || !isModFile(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				w.watchDirs()
+			}
+			w.timer.Reset(w.delay)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println("Error watching mods folder:", err)
+		}
+	}
+}
+
+func (w *ModWatcher) reload() {
+	// folders may have been added while waiting
+	w.watchDirs()
+	w.state.reloadEvents()
+}
+
+// Reports whether a change to path can change the events, which is the case
+// for scripts, manifests, the mod config and folders
+func isModFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".toml", ".json", "":
+		return true
+	}
+	return false
+}
+
+// Rebuilds the events from the mod scripts while the game runs. Events that
+// still exist keep their done flags, the errors of mods that were skipped
+// are shown in the message log.
+func (a *AppState) reloadEvents() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	done := a.DoneEvents()
+	schedules := map[string]ScriptSchedule{}
+	for _, event := range a.Events {
+		if event.Schedule != nil {
+			schedules[event.Name] = *event.Schedule
+		}
+	}
+	a.Events = GetEvents(a)
+	a.SetDoneEvents(done)
+	a.rescheduleEvents(schedules)
+	NewEventHandler(a).reload()
+
+	warnings := skippedModWarnings(a.scriptErrors)
+	if len(warnings) == 0 {
+		a.Messages.Prepend("Mods reloaded.")
+	}
+	for _, warning := range warnings {
+		a.Messages.Prepend(warning)
+	}
+}
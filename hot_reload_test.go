@@ -0,0 +1,129 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// -----------------------------
+// Tests for reloading mods while the game runs
+// -----------------------------
+
+// Points the mods folder to a temporary folder with files and returns its path
+func newTestModsDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	modPath := filepath.Join(home, "Documents", "IdleYou", "mods")
+	writeTestMod(t, modPath, files)
+	return modPath
+}
+
+func TestReloadEvents(t *testing.T) {
+	modPath := newTestModsDir(t, map[string]string{
+		"default/scripts/script.txt": "=== Raise\n? true\n! salary += 10\n> true\n\n=== Removed\n? true\n> true\n",
+	})
+	state := NewAppStateWithDefaults()
+	state.gameTick()
+	if done := state.DoneEvents(); len(done) != 2 {
+		t.Fatalf("Expected both events to be done, got %v", done)
+	}
+
+	writeTestMod(t, modPath, map[string]string{
+		"default/scripts/script.txt": "=== Raise\n? true\n! salary += 10\n> true\n\n=== Added\n? false\n",
+		"broken/scripts/script.txt":  "=== Broken\n> sometimes\n",
+	})
+	state.reloadEvents()
+
+	if len(state.Events) != 2 || state.GetEvent("default/Added") == nil {
+		t.Errorf("Expected the events of the changed script, got %v", state.Events)
+	}
+	if done := state.DoneEvents(); len(done) != 1 || done[0] != "default/Raise" {
+		t.Errorf("Expected only default/Raise to still be done, got %v", done)
+	}
+	messages, _ := state.Messages.Get()
+	if len(messages) == 0 || !strings.Contains(messages[0], "Mod broken was skipped") {
+		t.Errorf("Expected a warning about the broken mod in the message log, got %v", messages)
+	}
+}
+
+func TestModWatcher(t *testing.T) {
+	modPath := newTestModsDir(t, map[string]string{
+		"default/scripts/script.txt": "=== Raise\n! salary += 10\n",
+	})
+	state := NewAppStateWithDefaults()
+	watcher, err := NewModWatcher(state, modPath, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error watching mods folder: %s", err)
+	}
+	defer watcher.Close()
+
+	// a new mod in a new folder has to be picked up too
+	writeTestMod(t, modPath, map[string]string{
+		"chef/scripts/script.txt": "=== Cook\n! food += 10\n",
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		state.mu.Lock()
+		found := state.GetEvent("chef/Cook") != nil
+		state.mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the events to be reloaded after a mod was added")
+}
+
+func TestReloadProgressEvents(t *testing.T) {
+	modPath := newTestModsDir(t, map[string]string{
+		"default/scripts/script.txt": "=== Reading\n? true\n% 3\n! salary += 10\n> true\n\n=== Cooking\n? true\n% 2\n! salary += 1\n> true\n",
+	})
+	state := NewAppStateWithDefaults()
+	state.Food.Set(200)
+	state.gameTick()
+	checkBindingString(t, state.ProgressEventName, "default/Reading")
+	checkBindingStringList(t, state.QueuedEvents, []string{"default/Cooking"})
+
+	writeTestMod(t, modPath, map[string]string{
+		"default/scripts/script.txt": "=== Reading\n? true\n% 3\n! salary += 100\n> true\n",
+	})
+	state.reloadEvents()
+	checkBindingStringList(t, state.QueuedEvents, []string{})
+
+	for range 5 {
+		state.gameTick()
+	}
+	checkBindingString(t, state.ProgressEventName, "")
+	if salary := state.Get("salary"); salary != 100 {
+		t.Errorf("Expected the reloaded progress event to finish, salary is %v", salary)
+	}
+}
+
+func TestReloadTimers(t *testing.T) {
+	modPath := newTestModsDir(t, map[string]string{
+		"default/scripts/script.txt": "=== Payday\n@ every 10\n! money += 1\n\n=== Removed\n@ every 5\n! mood += 1\n",
+	})
+	state := NewAppStateWithDefaults()
+	state.Trigger("default/Removed", 2)
+
+	writeTestMod(t, modPath, map[string]string{
+		"default/scripts/script.txt": "=== Payday\n@ every 3\n! money += 1\n",
+	})
+	state.reloadEvents()
+
+	timers := state.timers.Timers()
+	if len(timers) != 1 || timers[0] != (Timer{3, "default/Payday", true}) {
+		t.Errorf("Expected only the new Payday timer, got %v", timers)
+	}
+}
@@ -8,6 +8,8 @@
 package main
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"fyne.io/fyne/v2/data/binding"
@@ -23,7 +25,10 @@ func TestAppStateFromJSONtoJSON(t *testing.T) {
 		t.Errorf("Error converting AppState to JSON: %s", err)
 		return
 	}
-	appState := fromJSON(jsonString)
+	appState, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
 
 	if appState == nil {
 		t.Errorf("Expected appState to be non-nil")
@@ -60,6 +65,152 @@ func TestAppStateFromJSONtoJSON(t *testing.T) {
 	checkBindingUntypedMap(t, appState.Variables, map[string]any{})
 }
 
+func TestAppStateJSONDoneEventsAndButtons(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	if len(state.Events) == 0 {
+		t.Skip("No events found")
+	}
+	doneEvent := state.Events[0].Name
+	state.Events[0].Done = true
+	state.AddButton("Watch TV", "default/Watching TV")
+	state.AddButton("Removed", "default/Removed")
+	state.RemoveButton("Removed")
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	appState, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+
+	doneEvents := appState.DoneEvents()
+	if len(doneEvents) != 1 || doneEvents[0] != doneEvent {
+		t.Errorf("Expected done events [%s], got %v", doneEvent, doneEvents)
+	}
+
+	buttons := appState.GetButtons()
+	if len(buttons) != 1 || buttons["Watch TV"] != "default/Watching TV" {
+		t.Errorf("Expected only the Watch TV button, got %v", buttons)
+	}
+}
+
+func TestFirstRunSaveKeepsDoneEventsAndButtons(t *testing.T) {
+	// an empty mods folder, so the default mod is written on the first run
+	t.Setenv("HOME", t.TempDir())
+
+	state := NewAppStateWithDefaults()
+	if len(state.Events) == 0 || !strings.HasPrefix(state.Events[0].Name, "default/") {
+		t.Fatalf("Expected the events of the default mod, got %v", state.Events)
+	}
+	doneEvent := state.Events[0].Name
+	state.Events[0].Done = true
+	state.AddButton("Watch TV", "default/Watching TV")
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	// the mods folder exists now, so the events are read from it
+	appState, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+
+	doneEvents := appState.DoneEvents()
+	if len(doneEvents) != 1 || doneEvents[0] != doneEvent {
+		t.Errorf("Expected done events [%s], got %v", doneEvent, doneEvents)
+	}
+	if buttons := appState.GetButtons(); buttons["Watch TV"] != "default/Watching TV" {
+		t.Errorf("Expected the Watch TV button, got %v", buttons)
+	}
+	if appState.GetEvent(doneEvent) == nil {
+		t.Errorf("Expected the event %s to exist after loading", doneEvent)
+	}
+}
+
+// -----------------------------
+// Tests for save migrations
+// -----------------------------
+
+func TestFromJSONMigratesUnversionedSave(t *testing.T) {
+	// a save from before the version field, missing a few values
+	appState, err := fromJSON(`{"ticks": 42, "money": 300, "job": "Sales clerk", "variables": {"myVariable": 3}}`)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+	if appState == nil {
+		t.Fatalf("Expected appState to be non-nil")
+	}
+	checkBindingInt(t, appState.Ticks, 42)
+	checkBindingInt(t, appState.Money, 300)
+	checkBindingString(t, appState.Job, "Sales clerk")
+	checkBindingInt(t, appState.Food, 200)
+	checkBindingBool(t, appState.RoutineShower, true)
+	checkBindingUntypedMap(t, appState.Variables, map[string]any{"myVariable": 3})
+	if len(appState.DoneEvents()) != 0 {
+		t.Errorf("Expected no done events, got %v", appState.DoneEvents())
+	}
+}
+
+func TestFromJSONRejectsNewerVersion(t *testing.T) {
+	appState, err := fromJSON(`{"version": 9999}`)
+	if appState != nil || err == nil {
+		t.Errorf("Expected save with unknown version to be rejected")
+	}
+}
+
+func TestFromJSONRejectsWrongTypes(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected string
+	}{
+		{`{"ticks": "many"}`, "ticks must be a number, got a string"},
+		{`{"doneEvents": [1]}`, "doneEvents[0] must be a string, got a number"},
+		{`{"buttons": {"Watch TV": true}}`, "buttons.Watch TV must be a string, got true or false"},
+		{`{"timers": [{"tick": 5, "event": null}]}`, "timers[0].event must be a string, got null"},
+		{`{"eventRuns": {"default/Raise": []}}`, "eventRuns.default/Raise must be an object, got an array"},
+		{`{"savedAt": "yesterday"}`, "savedAt must be a number, got a string"},
+		{`{"seed": 42}`, "seed must be a string, got a number"},
+		{`{"timers": [{"tick": 5, "event": "Raise", "scheduled": "yes"}]}`, "timers[0].scheduled must be true or false, got a string"},
+	}
+	for _, test := range tests {
+		appState, err := fromJSON(test.json)
+		if appState != nil || err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected %s to be rejected with %q, got %v", test.json, test.expected, err)
+		}
+	}
+}
+
+func TestDefaultSaveDataMatchesDefaults(t *testing.T) {
+	jsonString, err := NewAppStateWithDefaults().toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	var data map[string]any
+	err = json.Unmarshal([]byte(jsonString), &data)
+	if err != nil {
+		t.Fatalf("Error decoding JSON: %s", err)
+	}
+	defaults := defaultSaveData()
+	for key, value := range data {
+		if key == "version" || key == "doneEvents" || key == "savedAt" || key == "seed" {
+			continue
+		}
+		expected, ok := defaults[key]
+		if !ok {
+			t.Errorf("defaultSaveData is missing %s", key)
+			continue
+		}
+		expectedJSON, _ := json.Marshal(expected)
+		valueJSON, _ := json.Marshal(value)
+		if string(expectedJSON) != string(valueJSON) {
+			t.Errorf("Expected default %s to be %s, got %s", key, valueJSON, expectedJSON)
+		}
+	}
+}
+
 // Helper functions to check binding values
 
 func checkBindingInt(t *testing.T, b binding.Int, expected int) {
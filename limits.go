@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"sync"
+)
+
+// How often an event ran and when it ran last, used for the cooldown and
+// max lines of events
+type EventRuns struct {
+	Count    int `json:"count"`
+	LastTick int `json:"lastTick"`
+}
+
+// EventRunLog keeps the EventRuns of every event that ran at least once
+type EventRunLog struct {
+	mu   sync.Mutex
+	runs map[string]EventRuns
+}
+
+// Reports whether the cooldown and max of event allow it to run at tick
+func (l *EventRunLog) Allowed(event *Event, tick int) bool {
+	if event.Cooldown == 0 && event.MaxRuns == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	runs, ok := l.runs[event.Name]
+	if !ok {
+		return true
+	}
+	if event.MaxRuns > 0 && runs.Count >= event.MaxRuns {
+		return false
+	}
+	return event.Cooldown == 0 || tick-runs.LastTick >= event.Cooldown
+}
+
+// Records that the event called eventName ran at tick
+func (l *EventRunLog) Add(eventName string, tick int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.runs == nil {
+		l.runs = map[string]EventRuns{}
+	}
+	runs := l.runs[eventName]
+	runs.Count++
+	runs.LastTick = tick
+	l.runs[eventName] = runs
+}
+
+// Returns a copy of the runs of all events
+func (l *EventRunLog) Runs() map[string]EventRuns {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	runs := make(map[string]EventRuns, len(l.runs))
+	for name, r := range l.runs {
+		runs[name] = r
+	}
+	return runs
+}
+
+// Replaces the runs of all events
+func (l *EventRunLog) SetRuns(runs map[string]EventRuns) {
+	copied := make(map[string]EventRuns, len(runs))
+	for name, r := range runs {
+		copied[name] = r
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.runs = copied
+}
@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"testing"
+)
+
+// -----------------------------
+// Tests for cooldown and max
+// -----------------------------
+
+func TestParseLimits(t *testing.T) {
+	script := `=== Bullied at work
+? working
+cooldown 300
+max 5
+! mood -= 10
+
+=== Broken
+cooldown
+max -1
+if true
+  cooldown 5
+end`
+
+	scriptEvents, err := parseScript(script)
+	if scriptEvents[0].Cooldown != 300 || scriptEvents[0].MaxRuns != 5 {
+		t.Errorf("Expected cooldown 300 and max 5, got %d and %d", scriptEvents[0].Cooldown, scriptEvents[0].MaxRuns)
+	}
+
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("Expected ScriptErrors, got %v", err)
+	}
+	expected := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{8, 9, "cooldown needs a number"},
+		{9, 5, "max must be a number greater than 0: -1"},
+		{11, 3, "cooldown can't be used inside an if block"},
+	}
+	if len(scriptErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(scriptErrors), scriptErrors)
+	}
+	for i, e := range expected {
+		scriptError := scriptErrors[i]
+		if scriptError.Line != e.line || scriptError.Column != e.column || scriptError.Message != e.message {
+			t.Errorf("Expected %d:%d: %s, got %s", e.line, e.column, e.message, scriptError)
+		}
+	}
+}
+
+func TestCooldownAndMax(t *testing.T) {
+	state := newScriptedState(t, `=== Cooldown
+? true
+cooldown 3
+! cooled += 1
+
+=== Max
+? true
+max 2
+! maxed += 1
+
+=== Triggered
+max 1
+! triggered += 1`)
+	for _, name := range []string{"cooled", "maxed", "triggered"} {
+		state.Set(name, 0)
+	}
+
+	for range 10 {
+		state.Trigger("Triggered", 0)
+		state.gameTick()
+	}
+
+	// ticks 1, 4, 7 and 10
+	if cooled := state.Get("cooled"); cooled != 4 {
+		t.Errorf("Expected the event with a cooldown to run 4 times, got %v", cooled)
+	}
+	if maxed := state.Get("maxed"); maxed != 2 {
+		t.Errorf("Expected the event with a max to run 2 times, got %v", maxed)
+	}
+	if triggered := state.Get("triggered"); triggered != 1 {
+		t.Errorf("Expected the triggered event to run once, got %v", triggered)
+	}
+}
+
+func TestEventRunsSurviveSaving(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.runLog.Add("default/Bullied at work", 120)
+	state.runLog.Add("default/Bullied at work", 450)
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	loaded, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+
+	runs := loaded.runLog.Runs()["default/Bullied at work"]
+	if runs != (EventRuns{2, 450}) {
+		t.Errorf("Expected 2 runs with the last at tick 450, got %+v", runs)
+	}
+}
@@ -0,0 +1,316 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Checks the mod in dir, or every mod in dir if dir is the mods folder itself,
+// without starting the game
+func lint(dir string) (ScriptErrors, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var modDirs []string
+	for _, entry := range entries {
+		modDir := filepath.Join(dir, entry.Name())
+		if entry.IsDir() && isModDir(modDir) {
+			modDirs = append(modDirs, modDir)
+		}
+	}
+	if len(modDirs) == 0 {
+		if !isModDir(dir) {
+			return nil, fmt.Errorf("no scripts folder found in %s", dir)
+		}
+		return lintMod(dir, filepath.Base(dir))
+	}
+
+	// dir is the mods folder, scripts right inside it belong to a mod
+	// without a name like in findMods
+	var lintErrors ScriptErrors
+	if isModDir(dir) {
+		modErrors, err := lintMod(dir, "")
+		if err != nil {
+			return nil, err
+		}
+		lintErrors = append(lintErrors, modErrors...)
+	}
+	for _, modDir := range modDirs {
+		modErrors, err := lintMod(modDir, filepath.Base(modDir))
+		if err != nil {
+			return nil, err
+		}
+		lintErrors = append(lintErrors, modErrors...)
+	}
+	return lintErrors, nil
+}
+
+// A reference to an event or image that can only be checked once all
+// script files of a mod have been read
+type lintReference struct {
+	file   string
+	line   int
+	column int
+	name   string
+}
+
+// Checks all script files in the scripts folder of a single mod. modName
+// is "" for scripts right inside the mods folder.
+func lintMod(modDir string, modName string) (ScriptErrors, error) {
+	paths, err := filepath.Glob(filepath.Join(modDir, "scripts", "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	// the folder with the other mods
+	modPath := filepath.Dir(modDir)
+	if modName == "" {
+		modPath = modDir
+	}
+	var lintErrors ScriptErrors
+	manifest, err := readManifest(modDir)
+	if err != nil {
+		lintErrors = append(lintErrors, manifestError(modDir, modName, err))
+	}
+	mod := Mod{Name: modName, Dir: modDir, Manifest: manifest}
+	otherEvents := map[string]map[string]bool{}
+	events := map[string]bool{}
+	var eventReferences, imageReferences, variableReferences []lintReference
+	addVariables := func(path string, line, column int, expr Expr) {
+		for _, name := range arithmeticVariables(expr) {
+			variableReferences = append(variableReferences, lintReference{path, line, column, name})
+		}
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		report := func(line, column int, format string, args ...any) {
+			lintErrors = append(lintErrors, &ScriptError{modName, path, line, column, fmt.Sprintf(format, args...)})
+		}
+
+		_, err = parseScript(string(data))
+		if scriptErrors, ok := err.(ScriptErrors); ok {
+			for _, scriptError := range scriptErrors {
+				scriptError.Mod = modName
+				scriptError.File = path
+			}
+			lintErrors = append(lintErrors, scriptErrors...)
+		}
+
+		// Collect event names and references, lines with syntax errors
+		// were already reported by parseScript
+		for i, line := range strings.Split(string(data), "\n") {
+			lineNumber := i + 1
+			column := strings.Index(line, strings.TrimSpace(line)) + 1
+			line = strings.TrimSpace(line)
+
+			// The action after the weight of a random branch
+			if isWeightLine(line) {
+				colon := strings.Index(line, ":")
+				rest := strings.TrimSpace(line[colon+1:])
+				column += strings.Index(line, rest)
+				line = "!" + strings.TrimPrefix(rest, "!")
+			}
+
+			switch {
+			case strings.HasPrefix(line, "==="):
+				modifies, name := parseEventHeader(line[3:])
+				if modifies != "" {
+					eventReferences = append(eventReferences, lintReference{path, lineNumber, column, name})
+					continue
+				}
+				if name != "" && events[name] {
+					report(lineNumber, column, "duplicate event name: %s", name)
+				}
+				events[name] = true
+
+			case strings.HasPrefix(line, "?"):
+				condition, err := parseCondition(line[1:])
+				if err == nil {
+					addVariables(path, lineNumber, column, condition.Expr)
+				}
+
+			case strings.HasPrefix(line, "if ") || strings.HasPrefix(line, "else if "):
+				_, expression, _ := strings.Cut(line, "if ")
+				condition, err := parseCondition(expression)
+				if err == nil {
+					addVariables(path, lineNumber, column, condition.Expr)
+				}
+
+			case strings.HasPrefix(line, "!"):
+				action, err := parseAction(line[1:])
+				if err != nil {
+					continue
+				}
+				if expr, ok := action.Value.(Expr); ok && action.Operator != "" {
+					addVariables(path, lineNumber, column, expr)
+					continue
+				}
+				if action.Operator != "" {
+					continue
+				}
+				switch action.Variable {
+				case "show":
+					imageReferences = append(imageReferences, lintReference{path, lineNumber, column, action.Value.(string)})
+				case "trigger":
+					eventReferences = append(eventReferences, lintReference{path, lineNumber, column, action.Value.(ScriptTrigger).EventName})
+				}
+
+			case strings.HasPrefix(line, "@"):
+				schedule, err := parseSchedule(line[1:])
+				if err == nil && schedule.EventName != "" {
+					eventReferences = append(eventReferences, lintReference{path, lineNumber, column, schedule.EventName})
+				}
+
+			case strings.HasPrefix(line, "*"):
+				_, eventName, _, err := parseChoice(line[1:])
+				if err == nil {
+					eventReferences = append(eventReferences, lintReference{path, lineNumber, column, eventName})
+				}
+
+			case strings.HasPrefix(line, "+"):
+				button, err := parseButton(line[1:])
+				if err == nil && button.EventName != "" {
+					eventReferences = append(eventReferences, lintReference{path, lineNumber, column, button.EventName})
+				}
+			}
+		}
+	}
+
+	for _, ref := range eventReferences {
+		other, eventName, found := strings.Cut(ref.name, "/")
+		switch {
+		case !found || other == modName:
+			if !events[strings.TrimPrefix(ref.name, modName+"/")] {
+				lintErrors = append(lintErrors, &ScriptError{modName, ref.file, ref.line, ref.column, fmt.Sprintf("event does not exist: %s", ref.name)})
+			}
+		case !mod.canReference(other):
+			lintErrors = append(lintErrors, &ScriptError{modName, ref.file, ref.line, ref.column, fmt.Sprintf("%s is an event of mod %s, add %s to the dependencies in the manifest", ref.name, other, other)})
+		default:
+			// Check events of other mods if they are installed next to this one
+			if _, ok := otherEvents[other]; !ok {
+				otherEvents[other] = modEventNames(filepath.Join(modPath, other))
+			}
+			if otherEvents[other] != nil && !otherEvents[other][eventName] {
+				lintErrors = append(lintErrors, &ScriptError{modName, ref.file, ref.line, ref.column, fmt.Sprintf("event does not exist: %s", ref.name)})
+			}
+		}
+	}
+	// Variables can be set by this mod and the mods it depends on
+	variables := modVariables(modDir)
+	for _, dependency := range manifest.Dependencies {
+		maps.Copy(variables, modVariables(filepath.Join(modPath, dependency)))
+	}
+	for _, ref := range variableReferences {
+		name := ref.name
+		if !variables[name] && !slices.Contains(builtinVariables, strings.ToLower(name)) && name != "rand" && name != "roll" {
+			lintErrors = append(lintErrors, &ScriptError{modName, ref.file, ref.line, ref.column, fmt.Sprintf("unknown variable: %s", name)})
+		}
+	}
+	for _, ref := range imageReferences {
+		if _, err := os.Stat(filepath.Join(modDir, "images", ref.name)); err != nil {
+			lintErrors = append(lintErrors, &ScriptError{modName, ref.file, ref.line, ref.column, fmt.Sprintf("image does not exist: images/%s", ref.name)})
+		}
+	}
+
+	sort.SliceStable(lintErrors, func(i, j int) bool {
+		if lintErrors[i].File != lintErrors[j].File {
+			return lintErrors[i].File < lintErrors[j].File
+		}
+		return lintErrors[i].Line < lintErrors[j].Line
+	})
+	return lintErrors, nil
+}
+
+// Returns the names of the custom variables the mod in modDir sets with
+// actions
+func modVariables(modDir string) map[string]bool {
+	variables := map[string]bool{}
+	var addActions func(actions []ScriptAction)
+	addActions = func(actions []ScriptAction) {
+		for _, action := range actions {
+			if action.Operator != "" {
+				variables[action.Variable] = true
+			}
+			if branches, ok := action.Value.([]ScriptBranch); ok {
+				for _, branch := range branches {
+					addActions(branch.ScriptActions)
+				}
+			}
+		}
+	}
+	paths, _ := filepath.Glob(filepath.Join(modDir, "scripts", "*.txt"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		events, _ := parseScript(string(data))
+		for _, event := range events {
+			addActions(event.ScriptActions)
+		}
+	}
+	return variables
+}
+
+// Returns the names of the events of the mod in modDir without the mod
+// name, or nil if there is no such mod
+func modEventNames(modDir string) map[string]bool {
+	paths, err := filepath.Glob(filepath.Join(modDir, "scripts", "*.txt"))
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		events, _ := parseScript(string(data))
+		for _, event := range events {
+			if event.Modifies == "" {
+				names[event.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// Runs the lint command and returns the exit code
+func runLint(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: idleyou lint <moddir>")
+		return 2
+	}
+	lintErrors, err := lint(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, lintError := range lintErrors {
+		fmt.Println(lintError)
+	}
+	if len(lintErrors) > 0 {
+		fmt.Printf("%d problem(s) found\n", len(lintErrors))
+		return 1
+	}
+	fmt.Println("No problems found")
+	return 0
+}
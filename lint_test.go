@@ -0,0 +1,190 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// -----------------------------
+// Tests for the script linter
+// -----------------------------
+
+func writeTestMod(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLintMod(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"images/ok.png": "",
+		"scripts/events.txt": `=== First
+? mood <= 10
+? mood 10
+! show ok.png
+! show missing.png
+! mood ++ 1
+* Go -> Second
+* Bad -> Nowhere
+> maybe`,
+		"scripts/more.txt": `=== Second
++ Button -> Missing
+! trigger Gone in 10`,
+	})
+
+	lintErrors, err := lint(dir)
+	if err != nil {
+		t.Fatalf("Error linting mod: %s", err)
+	}
+
+	expected := []struct {
+		file    string
+		line    int
+		column  int
+		message string
+	}{
+		{"events.txt", 3, 8, "unexpected 10"},
+		{"events.txt", 5, 1, "image does not exist: images/missing.png"},
+		{"events.txt", 6, 8, "unknown action operator ++"},
+		{"events.txt", 8, 1, "event does not exist: Nowhere"},
+		{"events.txt", 9, 3, "return value must be true or false"},
+		{"more.txt", 2, 1, "event does not exist: Missing"},
+		{"more.txt", 3, 1, "event does not exist: Gone"},
+	}
+	if len(lintErrors) != len(expected) {
+		t.Fatalf("Expected %d lint errors, got %d: %v", len(expected), len(lintErrors), lintErrors)
+	}
+	for i, e := range expected {
+		lintError := lintErrors[i]
+		if filepath.Base(lintError.File) != e.file || lintError.Line != e.line || lintError.Column != e.column || !strings.Contains(lintError.Message, e.message) {
+			t.Errorf("Expected %s:%d:%d: %s, got %s", e.file, e.line, e.column, e.message, lintError)
+		}
+	}
+}
+
+func TestLintModsFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"good/scripts/script.txt": "=== Fine\n? true\n! print Hello\n> true\n",
+		"bad/scripts/script.txt":  "=== Broken\n? true\n> sometimes\n",
+	})
+
+	lintErrors, err := lint(dir)
+	if err != nil {
+		t.Fatalf("Error linting mods: %s", err)
+	}
+	if len(lintErrors) != 1 || !strings.Contains(lintErrors[0].File, "bad") {
+		t.Errorf("Expected one lint error in the bad mod, got %v", lintErrors)
+	}
+}
+
+func TestLintRootMod(t *testing.T) {
+	// the game loads scripts right inside the mods folder as a mod without
+	// a name, so their events aren't prefixed with the folder name
+	dir := filepath.Join(t.TempDir(), "mods")
+	writeTestMod(t, dir, map[string]string{
+		"scripts/script.txt": `=== Start
+* Go -> Next
+* Prefixed -> mods/Next
+* Cook -> chef/Cook
+
+=== Next
+! print Hello`,
+		"chef/scripts/script.txt": "=== Cook\n! food += 10\n",
+	})
+
+	lintErrors, err := lint(dir)
+	if err != nil {
+		t.Fatalf("Error linting mods: %s", err)
+	}
+	expected := []string{
+		"3:1: mods/Next is an event of mod mods, add mods to the dependencies",
+		"4:1: chef/Cook is an event of mod chef, add chef to the dependencies",
+	}
+	if len(lintErrors) != len(expected) {
+		t.Fatalf("Expected %d lint errors, got %d: %v", len(expected), len(lintErrors), lintErrors)
+	}
+	for i, e := range expected {
+		if lintErrors[i].Mod != "" || !strings.Contains(lintErrors[i].Error(), e) {
+			t.Errorf("Expected %s in the root mod, got %s in mod %q", e, lintErrors[i], lintErrors[i].Mod)
+		}
+	}
+}
+
+func TestLintCrossModReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"default/scripts/script.txt": "=== Chose Retail\n! print Retail\n",
+		"retail/mod.toml":            `dependencies = ["default"]`,
+		"retail/scripts/script.txt": `=== Career fair
+* Retail -> default/Chose Retail
+* Nope -> default/Chose Nothing
+* Other -> other/Event
++ Me -> retail/Career fair`,
+	})
+
+	lintErrors, err := lint(filepath.Join(dir, "retail"))
+	if err != nil {
+		t.Fatalf("Error linting mod: %s", err)
+	}
+	expected := []string{
+		"3:1: event does not exist: default/Chose Nothing",
+		"4:1: other/Event is an event of mod other, add other to the dependencies",
+	}
+	if len(lintErrors) != len(expected) {
+		t.Fatalf("Expected %d lint errors, got %d: %v", len(expected), len(lintErrors), lintErrors)
+	}
+	for i, e := range expected {
+		if !strings.Contains(lintErrors[i].Error(), e) {
+			t.Errorf("Expected %s, got %s", e, lintErrors[i])
+		}
+	}
+}
+
+func TestLintUnknownVariables(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"scripts/script.txt": `=== Raise
+? savings * 2 > salary
+! savings += salary
+! money = salry * 2
+! job = Manager
+if bonus + 1 > 2
+  ! mood += rand * 10
+end`,
+	})
+
+	lintErrors, err := lint(dir)
+	if err != nil {
+		t.Fatalf("Error linting mod: %s", err)
+	}
+	expected := []string{
+		"4:1: unknown variable: salry",
+		"6:1: unknown variable: bonus",
+	}
+	if len(lintErrors) != len(expected) {
+		t.Fatalf("Expected %d lint errors, got %d: %v", len(expected), len(lintErrors), lintErrors)
+	}
+	for i, e := range expected {
+		if !strings.Contains(lintErrors[i].Error(), e) {
+			t.Errorf("Expected %s, got %s", e, lintErrors[i])
+		}
+	}
+}
@@ -9,6 +9,9 @@ package main
 
 import (
 	"embed"
+	"flag"
+	"fmt"
+	"os"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -16,20 +19,80 @@ import (
 )
 
 const (
+	// Version of the game, mods can require a minimum version in their manifest
+	GameVersion = "0.1.0"
 	// Define constants for game mechanics
 	GameSpeed = time.Millisecond * 100
+	// Default number of ticks between autosaves (one minute at normal speed)
+	AutosaveInterval = 600
+	// Number of autosave files that are rotated through
+	AutosaveSlots = 3
 )
 
 //go:embed script.txt
 var scriptFile embed.FS
 
 func main() {
-	appstate := NewAppStateWithDefaults()
+	autosaveInterval := flag.Int("autosave", AutosaveInterval, "number of ticks between autosaves, 0 disables autosaving")
+	simulateTicks := flag.Int("simulate", 0, "run a new game for this many ticks without a window and print a report")
+	strategy := flag.String("strategy", "first", "how choices are picked when simulating: first, random or script")
+	choicesPath := flag.String("choices", "", "file with the choices for the script strategy, one 'event name: button text' per line")
+	seed := flag.Uint64("seed", 0, "seed for the random numbers of a new game or simulation, 0 picks a random seed")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: idleyou [flags]\n       idleyou lint <moddir>\n\nflags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "lint" {
+		os.Exit(runLint(flag.Args()[1:]))
+	}
+
+	if *simulateTicks > 0 {
+		state := NewAppStateWithDefaults()
+		state.SetSeed(*seed)
+		choiceStrategy, err := NewChoiceStrategy(*strategy, *choicesPath, state.random.Seed())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		report := state.simulate(*simulateTicks, choiceStrategy)
+		fmt.Print(report)
+		return
+	}
+
+	appstate := loadLatestSaveOrDefaults(*seed)
+	offlineSummary := appstate.simulateOffline(time.Now())
 
 	a := app.New()
 	w := a.NewWindow("IdleYou")
 
-	content := setupUI(appstate)
+	content := setupUI(appstate, w)
+	if offlineSummary != nil {
+		showOfflineSummary(appstate, offlineSummary, w)
+	}
+
+	var autosaver *Autosaver
+	dir, err := savesDir()
+	if err != nil {
+		fmt.Println("Error finding saves, autosave disabled:", err)
+	} else {
+		autosaver = NewAutosaver(dir, *autosaveInterval, AutosaveSlots)
+		w.SetCloseIntercept(func() {
+			_, err := autosaver.Save(appstate)
+			if err != nil {
+				fmt.Println("Error saving on close:", err)
+			}
+			w.Close()
+		})
+	}
+
+	modWatcher, err := NewModWatcher(appstate, modsDir(), ModReloadDelay)
+	if err != nil {
+		fmt.Println("Error watching mods folder, mods are only loaded on start:", err)
+	} else {
+		defer modWatcher.Close()
+	}
 
 	appstate.gameTick()
 
@@ -39,6 +102,9 @@ func main() {
 	go func() {
 		for range time.Tick(GameSpeed) {
 			appstate.gameTick()
+			if autosaver != nil {
+				autosaver.Tick(appstate)
+			}
 		}
 	}()
 	w.ShowAndRun()
@@ -0,0 +1,285 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Version of the save format written by toJSON.
+// Increase it and add a migration whenever the save format changes.
+const saveVersion = 7
+
+// saveMigrations[i] upgrades save data from version i to version i+1.
+// Saves written before the version field was added are version 0.
+//
+// Migrations work on the decoded JSON, so numbers are float64, lists are
+// []any and objects are map[string]any.
+var saveMigrations = []func(data map[string]any){
+	// 0 -> 1: done events and persistent buttons
+	func(data map[string]any) {
+		setDefault(data, "doneEvents", []any{})
+		setDefault(data, "buttons", map[string]any{})
+	},
+	// 1 -> 2: time of saving for offline progress, 0 means unknown
+	func(data map[string]any) {
+		setDefault(data, "savedAt", float64(0))
+	},
+	// 2 -> 3: game over state
+	func(data map[string]any) {
+		setDefault(data, "gameOver", false)
+		setDefault(data, "gameOverReason", "")
+	},
+	// 3 -> 4: events triggered by scripts
+	func(data map[string]any) {
+		setDefault(data, "timers", []any{})
+	},
+	// 4 -> 5: seed of the random numbers, older saves get a new seed
+	func(data map[string]any) {
+		setDefault(data, "seed", "0")
+	},
+	// 5 -> 6: runs of events with a cooldown or max
+	func(data map[string]any) {
+		setDefault(data, "eventRuns", map[string]any{})
+	},
+	// 6 -> 7: queued progress events
+	func(data map[string]any) {
+		setDefault(data, "queuedEvents", []any{})
+	},
+}
+
+// Upgrades decoded save data to the current saveVersion
+func migrateSave(data map[string]any) error {
+	version := 0
+	if v, ok := data["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > saveVersion {
+		return fmt.Errorf("save version %d is newer than the supported version %d", version, saveVersion)
+	}
+	for ; version < saveVersion; version++ {
+		saveMigrations[version](data)
+	}
+	data["version"] = float64(saveVersion)
+
+	// Fill in anything that is still missing, so an incomplete save
+	// starts with default values instead of failing to load
+	for key, value := range defaultSaveData() {
+		if _, ok := data[key]; !ok {
+			log.Printf("Save is missing %s, using default value %v\n", key, value)
+			data[key] = value
+		}
+	}
+	return nil
+}
+
+// Sets data[key] to value if data has no value for key yet
+func setDefault(data map[string]any, key string, value any) {
+	if _, ok := data[key]; !ok {
+		data[key] = value
+	}
+}
+
+// Returns the decoded save data of a new game, matching
+// NewAppStateWithDefaults
+func defaultSaveData() map[string]any {
+	return map[string]any{
+		"ticks":              float64(0),
+		"work":               float64(0),
+		"workXP":             float64(0),
+		"food":               float64(200),
+		"foodMax":            float64(200),
+		"energy":             float64(100),
+		"energyMax":          float64(100),
+		"mood":               float64(50),
+		"money":              float64(100),
+		"charisma":           float64(0),
+		"fitness":            float64(0),
+		"job":                "",
+		"salary":             float64(0),
+		"working":            false,
+		"paused":             false,
+		"gameOver":           false,
+		"gameOverReason":     "",
+		"routineShower":      true,
+		"routineShave":       false,
+		"routineBrushTeeth":  true,
+		"routineBonus":       float64(0),
+		"progressEventName":  "",
+		"progressEventValue": float64(0),
+		"progressEventMax":   float64(100),
+		"choiceEventName":    "",
+		"choiceEventText":    "",
+		"choiceEventChoices": []any{},
+		"messages":           []any{},
+		"variables":          map[string]any{},
+		"doneEvents":         []any{},
+		"buttons":            map[string]any{},
+		"savedAt":            float64(0),
+		"timers":             []any{},
+		"seed":               "0",
+		"eventRuns":          map[string]any{},
+		"queuedEvents":       []any{},
+	}
+}
+
+// saveReader reads values of the expected type from decoded save data. The
+// first value with the wrong type is remembered as the error and reads
+// return zero values after that, so the caller only checks Err once.
+type saveReader struct {
+	data map[string]any
+	// where data is in the save, for error messages
+	path string
+	// shared with the readers of nested objects
+	err *error
+}
+
+func newSaveReader(data map[string]any) *saveReader {
+	return &saveReader{data: data, err: new(error)}
+}
+
+// Returns the first value that had the wrong type
+func (r *saveReader) Err() error {
+	return *r.err
+}
+
+// Returns the path of key in the save, like timers[0].tick
+func (r *saveReader) name(key string) string {
+	if r.path == "" {
+		return key
+	}
+	return r.path + "." + key
+}
+
+func (r *saveReader) fail(name string, expected string, value any) {
+	if *r.err == nil {
+		*r.err = fmt.Errorf("invalid save data: %s must be %s, got %s", name, expected, jsonType(value))
+	}
+}
+
+func (r *saveReader) Int(key string) int {
+	f, ok := r.data[key].(float64)
+	if !ok {
+		r.fail(r.name(key), "a number", r.data[key])
+	}
+	return int(f)
+}
+
+func (r *saveReader) String(key string) string {
+	s, ok := r.data[key].(string)
+	if !ok {
+		r.fail(r.name(key), "a string", r.data[key])
+	}
+	return s
+}
+
+func (r *saveReader) Bool(key string) bool {
+	b, ok := r.data[key].(bool)
+	if !ok {
+		r.fail(r.name(key), "true or false", r.data[key])
+	}
+	return b
+}
+
+// Returns false if key is missing, like for fields saved with omitempty
+func (r *saveReader) OptionalBool(key string) bool {
+	if _, ok := r.data[key]; !ok {
+		return false
+	}
+	return r.Bool(key)
+}
+
+// Returns the object at key
+func (r *saveReader) Map(key string) map[string]any {
+	m, ok := r.data[key].(map[string]any)
+	if !ok {
+		r.fail(r.name(key), "an object", r.data[key])
+		return map[string]any{}
+	}
+	return m
+}
+
+// Returns the array of strings at key
+func (r *saveReader) Strings(key string) []string {
+	list, ok := r.data[key].([]any)
+	if !ok {
+		r.fail(r.name(key), "an array", r.data[key])
+	}
+	values := make([]string, 0, len(list))
+	for i, value := range list {
+		s, ok := value.(string)
+		if !ok {
+			r.fail(fmt.Sprintf("%s[%d]", r.name(key), i), "a string", value)
+		}
+		values = append(values, s)
+	}
+	return values
+}
+
+// Returns the object of strings at key
+func (r *saveReader) StringMap(key string) map[string]string {
+	values := map[string]string{}
+	for k, value := range r.Map(key) {
+		s, ok := value.(string)
+		if !ok {
+			r.fail(r.name(key)+"."+k, "a string", value)
+		}
+		values[k] = s
+	}
+	return values
+}
+
+// Returns readers for the objects in the array at key
+func (r *saveReader) Objects(key string) []*saveReader {
+	list, ok := r.data[key].([]any)
+	if !ok {
+		r.fail(r.name(key), "an array", r.data[key])
+	}
+	readers := make([]*saveReader, 0, len(list))
+	for i, value := range list {
+		readers = append(readers, r.object(fmt.Sprintf("%s[%d]", r.name(key), i), value))
+	}
+	return readers
+}
+
+// Returns readers for the objects in the object at key, by their keys
+func (r *saveReader) ObjectMap(key string) map[string]*saveReader {
+	readers := map[string]*saveReader{}
+	for k, value := range r.Map(key) {
+		readers[k] = r.object(r.name(key)+"."+k, value)
+	}
+	return readers
+}
+
+func (r *saveReader) object(name string, value any) *saveReader {
+	data, ok := value.(map[string]any)
+	if !ok {
+		r.fail(name, "an object", value)
+	}
+	return &saveReader{data: data, path: name, err: r.err}
+}
+
+// Returns the name of the JSON type of a decoded value
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case bool:
+		return "true or false"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
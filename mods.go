@@ -0,0 +1,417 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ModManifest describes a mod, it is read from the optional mod.toml or
+// mod.json file in the folder of the mod
+type ModManifest struct {
+	// Name shown to the player, the folder name if empty
+	Name        string `toml:"name" json:"name"`
+	Author      string `toml:"author" json:"author"`
+	Version     string `toml:"version" json:"version"`
+	Description string `toml:"description" json:"description"`
+	// Oldest version of the game the mod works with
+	GameVersion string `toml:"game_version" json:"gameVersion"`
+	// Mods with a higher priority are loaded first, the default is 0
+	Priority int `toml:"priority" json:"priority"`
+	// Folder names of the mods this mod needs, they are loaded before it
+	Dependencies []string `toml:"dependencies" json:"dependencies"`
+}
+
+// Mod is a folder in the mods folder that has a scripts folder
+type Mod struct {
+	// Name of the folder, the events of the mod are prefixed with it
+	Name     string
+	Dir      string
+	Manifest ModManifest
+}
+
+// Returns the name of the mod shown to the player
+func (m Mod) DisplayName() string {
+	if m.Manifest.Name != "" {
+		return m.Manifest.Name
+	}
+	if m.Name == "" {
+		return "(root)"
+	}
+	return m.Name
+}
+
+func (m Mod) String() string {
+	s := m.DisplayName()
+	if m.Manifest.Version != "" {
+		s += " " + m.Manifest.Version
+	}
+	if m.Manifest.Author != "" {
+		s += " by " + m.Manifest.Author
+	}
+	return s
+}
+
+// ModStatus is what the mod manager shows about a mod
+type ModStatus struct {
+	Mod
+	Enabled bool
+	// Whether the events of the mod are in the game
+	Loaded bool
+	Events int
+	// Why the mod couldn't be loaded
+	Errors ScriptErrors
+}
+
+// Returns the version, author and number of events of the mod
+func (s ModStatus) Summary() string {
+	var parts []string
+	if s.Manifest.Version != "" {
+		parts = append(parts, "version "+s.Manifest.Version)
+	}
+	if s.Manifest.Author != "" {
+		parts = append(parts, "by "+s.Manifest.Author)
+	}
+	if s.Events == 1 {
+		parts = append(parts, "1 event")
+	} else {
+		parts = append(parts, fmt.Sprintf("%d events", s.Events))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ModConfig holds the choices of the player in the mod manager, it is stored
+// in config.json in the mods folder. Mods are enabled unless they are
+// disabled in the config, so new mods are loaded right away.
+type ModConfig struct {
+	Disabled []string `json:"disabled"`
+}
+
+// Returns the path of the mod config
+func modConfigPath() string {
+	return filepath.Join(modsDir(), "config.json")
+}
+
+// Reads the mod config, a missing file is an empty config
+func loadModConfig(path string) (ModConfig, error) {
+	var config ModConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+func saveModConfig(path string, config ModConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Reports whether the mod called name is enabled
+func (c ModConfig) Enabled(name string) bool {
+	return !slices.Contains(c.Disabled, name)
+}
+
+func (c *ModConfig) SetEnabled(name string, enabled bool) {
+	c.Disabled = slices.DeleteFunc(c.Disabled, func(disabled string) bool {
+		return disabled == name
+	})
+	if !enabled {
+		c.Disabled = append(c.Disabled, name)
+		slices.Sort(c.Disabled)
+	}
+}
+
+// Finds the mods in modPath in the order they are loaded in, by priority
+// and then by folder name. Mods with a broken manifest or that need a newer
+// version of the game are left out and reported in the returned errors.
+func findMods(modPath string) ([]Mod, ScriptErrors) {
+	var dirs []string
+	// scripts right inside the mods folder belong to a mod without a name
+	if isModDir(modPath) {
+		dirs = append(dirs, modPath)
+	}
+	entries, err := os.ReadDir(modPath)
+	if err != nil {
+		return nil, ScriptErrors{{File: modPath, Message: err.Error()}}
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(modPath, entry.Name())
+		if entry.IsDir() && isModDir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	var mods []Mod
+	var errs ScriptErrors
+	for _, dir := range dirs {
+		mod := Mod{Name: filepath.Base(dir), Dir: dir}
+		if dir == modPath {
+			mod.Name = ""
+		}
+		manifest, err := readManifest(dir)
+		if err != nil {
+			scriptError := manifestError(dir, mod.Name, err)
+			if relPath, relErr := filepath.Rel(modPath, scriptError.File); relErr == nil {
+				scriptError.File = relPath
+			}
+			errs = append(errs, scriptError)
+			continue
+		}
+		mod.Manifest = manifest
+		if manifest.GameVersion != "" && compareVersions(GameVersion, manifest.GameVersion) < 0 {
+			errs = append(errs, &ScriptError{Mod: mod.Name, File: mod.Name, Message: fmt.Sprintf("mod needs game version %s or newer, this is version %s", manifest.GameVersion, GameVersion)})
+			continue
+		}
+		mods = append(mods, mod)
+	}
+
+	sort.SliceStable(mods, func(i, j int) bool {
+		if mods[i].Manifest.Priority != mods[j].Manifest.Priority {
+			return mods[i].Manifest.Priority > mods[j].Manifest.Priority
+		}
+		return mods[i].Name < mods[j].Name
+	})
+	return mods, errs
+}
+
+// Leaves out the mods that depend on a mod that isn't in mods and orders the
+// rest so every mod comes after its dependencies, otherwise keeping the order
+// of mods
+func resolveDependencies(mods []Mod) ([]Mod, ScriptErrors) {
+	var errs ScriptErrors
+	available := map[string]bool{}
+	for _, mod := range mods {
+		available[mod.Name] = true
+	}
+	// Leaving out a mod can leave other mods without a dependency, so repeat
+	// until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for _, mod := range mods {
+			if !available[mod.Name] {
+				continue
+			}
+			for _, dependency := range mod.Manifest.Dependencies {
+				if !available[dependency] {
+					errs = append(errs, &ScriptError{Mod: mod.Name, File: mod.Name, Message: fmt.Sprintf("missing dependency: %s is not installed, disabled or was skipped", dependency)})
+					available[mod.Name] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// Repeatedly take the first mod whose dependencies were all taken
+	var ordered []Mod
+	placed := map[string]bool{}
+	for {
+		progress := false
+		for _, mod := range mods {
+			if !available[mod.Name] || placed[mod.Name] {
+				continue
+			}
+			ready := true
+			for _, dependency := range mod.Manifest.Dependencies {
+				if !placed[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, mod)
+				placed[mod.Name] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+	// What is left depends on itself in a circle
+	for _, mod := range mods {
+		if available[mod.Name] && !placed[mod.Name] {
+			errs = append(errs, &ScriptError{Mod: mod.Name, File: mod.Name, Message: fmt.Sprintf("circular dependency: %s", strings.Join(mod.Manifest.Dependencies, ", "))})
+		}
+	}
+	return ordered, errs
+}
+
+// Reports whether the mod can use the events of the mod called name
+func (m Mod) canReference(name string) bool {
+	return name == m.Name || slices.Contains(m.Manifest.Dependencies, name)
+}
+
+// Reports whether dir has a scripts folder
+func isModDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "scripts"))
+	return err == nil && info.IsDir()
+}
+
+// An error in a manifest file, with the position of the problem if known
+type manifestSyntaxError struct {
+	file    string
+	line    int
+	column  int
+	message string
+}
+
+func (e *manifestSyntaxError) Error() string {
+	return e.message
+}
+
+// Turns an error from reading the manifest of the mod in dir into a
+// ScriptError, with the path of the manifest file if it is known
+func manifestError(dir, modName string, err error) *ScriptError {
+	scriptError := &ScriptError{Mod: modName, File: dir, Message: err.Error()}
+	var se *manifestSyntaxError
+	if errors.As(err, &se) {
+		scriptError.File = se.file
+		scriptError.Line = se.line
+		scriptError.Column = se.column
+	}
+	return scriptError
+}
+
+// Reads the manifest of the mod in dir. A mod without a manifest gets an
+// empty one, so all fields have their default values.
+func readManifest(dir string) (ModManifest, error) {
+	var manifest ModManifest
+	tomlPath := filepath.Join(dir, "mod.toml")
+	jsonPath := filepath.Join(dir, "mod.json")
+	tomlData, tomlErr := os.ReadFile(tomlPath)
+	jsonData, jsonErr := os.ReadFile(jsonPath)
+
+	switch {
+	case tomlErr == nil && jsonErr == nil:
+		return manifest, fmt.Errorf("mod has both a mod.toml and a mod.json, remove one of them")
+
+	case tomlErr == nil:
+		metaData, err := toml.Decode(string(tomlData), &manifest)
+		var parseError toml.ParseError
+		if errors.As(err, &parseError) {
+			message := parseError.Message
+			if message == "" {
+				message = parseError.Error()
+			}
+			line, column := lineColumn(tomlData, parseError.Position.Start)
+			return manifest, &manifestSyntaxError{tomlPath, line, column, message}
+		}
+		if err != nil {
+			return manifest, &manifestSyntaxError{tomlPath, 0, 0, err.Error()}
+		}
+		if undecoded := metaData.Undecoded(); len(undecoded) > 0 {
+			return manifest, &manifestSyntaxError{tomlPath, 0, 0, fmt.Sprintf("unknown field: %s", undecoded[0])}
+		}
+		return manifest, validateManifest(manifest, tomlPath)
+
+	case jsonErr == nil:
+		decoder := json.NewDecoder(bytes.NewReader(jsonData))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&manifest)
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			line, column := lineColumn(jsonData, int(syntaxError.Offset))
+			return manifest, &manifestSyntaxError{jsonPath, line, column, syntaxError.Error()}
+		}
+		if err != nil {
+			return manifest, &manifestSyntaxError{jsonPath, 0, 0, strings.TrimPrefix(err.Error(), "json: ")}
+		}
+		return manifest, validateManifest(manifest, jsonPath)
+
+	case !errors.Is(tomlErr, os.ErrNotExist):
+		return manifest, tomlErr
+	case !errors.Is(jsonErr, os.ErrNotExist):
+		return manifest, jsonErr
+	}
+	return manifest, nil
+}
+
+// Checks the values of a manifest that was read from path
+func validateManifest(manifest ModManifest, path string) error {
+	if manifest.GameVersion != "" {
+		if _, err := parseVersion(manifest.GameVersion); err != nil {
+			return &manifestSyntaxError{path, 0, 0, err.Error()}
+		}
+	}
+	return nil
+}
+
+// Returns the line and column of the byte at offset in data, both
+// starting at 1
+func lineColumn(data []byte, offset int) (int, int) {
+	offset = min(max(offset, 0), len(data))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// Parses a version like 1.2.3 into its numbers
+func parseVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %s, expected numbers separated by dots like 1.2.0", version)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// Compares two versions like 1.2.3 number by number, missing numbers count
+// as 0. Returns -1 if a is older than b, 1 if it is newer and 0 if they are
+// the same. Versions that can't be parsed count as the same.
+func compareVersions(a, b string) int {
+	aNumbers, err := parseVersion(a)
+	if err != nil {
+		return 0
+	}
+	bNumbers, err := parseVersion(b)
+	if err != nil {
+		return 0
+	}
+	for i := range max(len(aNumbers), len(bNumbers)) {
+		var x, y int
+		if i < len(aNumbers) {
+			x = aNumbers[i]
+		}
+		if i < len(bNumbers) {
+			y = bNumbers[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,298 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// -----------------------------
+// Tests for mod manifests
+// -----------------------------
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"toml/scripts/script.txt": "",
+		"toml/mod.toml": `name = "Firefighter"
+author = "Jane Doe"
+version = "1.0.0"
+game_version = "0.1"
+priority = 10
+dependencies = ["default"]`,
+		"json/scripts/script.txt": "",
+		"json/mod.json":           `{"name": "Chef", "version": "2.1", "gameVersion": "0.1.0"}`,
+	})
+
+	manifest, err := readManifest(filepath.Join(dir, "toml"))
+	if err != nil {
+		t.Fatalf("Error reading mod.toml: %s", err)
+	}
+	expected := ModManifest{Name: "Firefighter", Author: "Jane Doe", Version: "1.0.0", GameVersion: "0.1", Priority: 10, Dependencies: []string{"default"}}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, manifest)
+	}
+
+	manifest, err = readManifest(filepath.Join(dir, "json"))
+	if err != nil {
+		t.Fatalf("Error reading mod.json: %s", err)
+	}
+	expected = ModManifest{Name: "Chef", Version: "2.1", GameVersion: "0.1.0"}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, manifest)
+	}
+}
+
+func TestModLoadOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"b/scripts/script.txt":     "",
+		"a/scripts/script.txt":     "",
+		"late/scripts/script.txt":  "",
+		"late/mod.toml":            "priority = -1",
+		"early/scripts/script.txt": "",
+		"early/mod.json":           `{"priority": 5}`,
+		"notamod/readme.txt":       "",
+	})
+
+	mods, errs := findMods(dir)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	var names []string
+	for _, mod := range mods {
+		names = append(names, mod.Name)
+	}
+	if order := strings.Join(names, " "); order != "early a b late" {
+		t.Errorf("Expected the load order early a b late, got %s", order)
+	}
+}
+
+func TestBrokenManifests(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"syntax/scripts/script.txt":  "",
+		"syntax/mod.toml":            "name = \"Broken\"\nversion = 1.0.0\n",
+		"unknown/scripts/script.txt": "",
+		"unknown/mod.toml":           `title = "Unknown"`,
+		"newer/scripts/script.txt":   "",
+		"newer/mod.json":             `{"gameVersion": "99.0"}`,
+		"both/scripts/script.txt":    "",
+		"both/mod.toml":              "",
+		"both/mod.json":              "{}",
+		"fine/scripts/script.txt":    "",
+	})
+
+	mods, errs := findMods(dir)
+	if len(mods) != 1 || mods[0].Name != "fine" {
+		t.Errorf("Expected only the fine mod to be loaded, got %v", mods)
+	}
+
+	expected := map[string]string{
+		"syntax":  "syntax/mod.toml:2:",
+		"unknown": "unknown field: title",
+		"newer":   "mod needs game version 99.0 or newer",
+		"both":    "both a mod.toml and a mod.json",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for _, err := range errs {
+		if !strings.Contains(filepath.ToSlash(err.Error()), expected[err.Mod]) {
+			t.Errorf("Expected the error of mod %s to contain %q, got %s", err.Mod, expected[err.Mod], err)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"0.1.0", "0.1", 0},
+		{"0.1.0", "0.2.0", -1},
+		{"1.10", "1.9", 1},
+		{"2", "1.9.9", 1},
+	}
+	for _, test := range tests {
+		if result := compareVersions(test.a, test.b); result != test.expected {
+			t.Errorf("compareVersions(%s, %s): expected %d, got %d", test.a, test.b, test.expected, result)
+		}
+	}
+}
+
+// -----------------------------
+// Tests for mod dependencies
+// -----------------------------
+
+func TestResolveDependencies(t *testing.T) {
+	newMod := func(name string, dependencies ...string) Mod {
+		return Mod{Name: name, Manifest: ModManifest{Dependencies: dependencies}}
+	}
+	mods := []Mod{
+		newMod("addon", "firefighter"),
+		newMod("firefighter", "default"),
+		newMod("default"),
+		newMod("orphan", "missing"),
+		newMod("orphanaddon", "orphan"),
+		newMod("chicken", "egg"),
+		newMod("egg", "chicken"),
+	}
+
+	loaded, errs := resolveDependencies(mods)
+	var names []string
+	for _, mod := range loaded {
+		names = append(names, mod.Name)
+	}
+	if order := strings.Join(names, " "); order != "default firefighter addon" {
+		t.Errorf("Expected the load order default firefighter addon, got %s", order)
+	}
+
+	expected := map[string]string{
+		"orphan":      "missing dependency: missing",
+		"orphanaddon": "missing dependency: orphan",
+		"chicken":     "circular dependency: egg",
+		"egg":         "circular dependency: chicken",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for _, err := range errs {
+		if !strings.Contains(err.Message, expected[err.Mod]) {
+			t.Errorf("Expected the error of mod %s to contain %q, got %s", err.Mod, expected[err.Mod], err)
+		}
+	}
+}
+
+func TestCrossModReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeTestMod(t, dir, map[string]string{
+		"retail/mod.toml": `dependencies = ["default"]`,
+		"retail/scripts/script.txt": `=== Career fair
+? true
+* Retail -> default/Chose Retail
+* Later -> Career fair
++ Apply -> default/Chose Retail
+! trigger retail/Career fair in 10`,
+		"sneaky/scripts/script.txt": `=== Shortcut
+! trigger default/Promotion to Manager in 1`,
+	})
+	mods, _ := findMods(dir)
+
+	events, errs := readMod(dir, mods[0])
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	event := events[0]
+	if event.Name != "retail/Career fair" {
+		t.Errorf("Expected the event name to be prefixed, got %s", event.Name)
+	}
+	if eventName := event.Choices["Retail"].EventName; eventName != "default/Chose Retail" {
+		t.Errorf("Expected the link to another mod to stay the same, got %s", eventName)
+	}
+	if eventName := event.Choices["Later"].EventName; eventName != "retail/Career fair" {
+		t.Errorf("Expected the link to the same mod to be prefixed, got %s", eventName)
+	}
+	if eventName := event.ScriptButtons[0].EventName; eventName != "default/Chose Retail" {
+		t.Errorf("Expected the button to link to default/Chose Retail, got %s", eventName)
+	}
+
+	_, errs = readMod(dir, mods[1])
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "add default to the dependencies") {
+		t.Errorf("Expected an error about the missing dependency, got %v", errs)
+	}
+}
+
+// -----------------------------
+// Tests for enabling and disabling mods
+// -----------------------------
+
+func TestModConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config, err := loadModConfig(path)
+	if err != nil || !config.Enabled("default") {
+		t.Fatalf("Expected a missing config to enable all mods, got %+v, %v", config, err)
+	}
+
+	config.SetEnabled("firefighter", false)
+	config.SetEnabled("chef", false)
+	config.SetEnabled("chef", false)
+	config.SetEnabled("firefighter", true)
+	if err := saveModConfig(path, config); err != nil {
+		t.Fatalf("Error saving mod config: %s", err)
+	}
+
+	loaded, err := loadModConfig(path)
+	if err != nil {
+		t.Fatalf("Error loading mod config: %s", err)
+	}
+	if !reflect.DeepEqual(loaded.Disabled, []string{"chef"}) {
+		t.Errorf("Expected only chef to be disabled, got %v", loaded.Disabled)
+	}
+}
+
+func TestReadScriptSkipsDisabledMods(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	modPath := filepath.Join(home, "Documents", "IdleYou", "mods")
+	writeTestMod(t, modPath, map[string]string{
+		"config.json":                `{"disabled": ["chef"]}`,
+		"default/scripts/script.txt": "=== Raise\n! salary += 10\n\n=== Bonus\n! money += 10\n",
+		"chef/mod.toml":              `version = "1.2"`,
+		"chef/scripts/script.txt":    "=== Cook\n! food += 10\n",
+		"addon/mod.toml":             `dependencies = ["chef"]`,
+		"addon/scripts/script.txt":   "=== patch chef/Cook\n! mood += 1\n",
+		"broken/scripts/script.txt":  "=== Broken\n> sometimes\n",
+	})
+
+	statuses, events, errs := readScript()
+	if len(events) != 2 || events[0].Name != "default/Raise" {
+		t.Errorf("Expected only the events of the default mod, got %v", events)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Expected errors for the addon and the broken mod, got %v", errs)
+	}
+
+	expected := map[string]ModStatus{
+		"addon":   {Enabled: true, Events: 1},
+		"broken":  {Enabled: true, Events: 1},
+		"chef":    {Enabled: false, Events: 1},
+		"default": {Enabled: true, Loaded: true, Events: 2},
+	}
+	if len(statuses) != len(expected) {
+		t.Fatalf("Expected %d mods, got %d: %v", len(expected), len(statuses), statuses)
+	}
+	for _, status := range statuses {
+		e := expected[status.Name]
+		if status.Enabled != e.Enabled || status.Loaded != e.Loaded || status.Events != e.Events {
+			t.Errorf("Expected mod %s to be %+v, got %+v", status.Name, e, status)
+		}
+		if hasErrors := len(status.Errors) > 0; hasErrors != (status.Name == "addon" || status.Name == "broken") {
+			t.Errorf("Unexpected errors of mod %s: %v", status.Name, status.Errors)
+		}
+	}
+}
+
+func TestReadScriptFirstRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	statuses, events, errs := readScript()
+	if len(events) == 0 || len(errs) != 0 {
+		t.Fatalf("Expected the events of the default script, got %d events and %v", len(events), errs)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Expected the default mod, got %v", statuses)
+	}
+	status := statuses[0]
+	if status.Name != "default" || !status.Enabled || !status.Loaded || status.Events != len(events) {
+		t.Errorf("Expected the default mod to be loaded with %d events, got %+v", len(events), status)
+	}
+}
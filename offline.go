@@ -0,0 +1,131 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Offline progress is only simulated for this long, no matter how long
+	// the game was closed
+	MaxOfflineTime = 8 * time.Hour
+)
+
+// What happened while the game was closed
+type OfflineSummary struct {
+	Duration    time.Duration
+	Ticks       int
+	MoneyChange int
+	FoodChange  int
+	Events      map[string]int
+}
+
+func (s *OfflineSummary) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "While you were away (%s):\n", s.Duration.Round(time.Minute))
+	fmt.Fprintf(&builder, "Money: %+d\n", s.MoneyChange)
+	fmt.Fprintf(&builder, "Food: %+d", s.FoodChange)
+
+	for _, name := range sortedKeys(s.Events) {
+		fmt.Fprintf(&builder, "\n%s (%dx)", getStringAfterSlash(name), s.Events[name])
+	}
+	return builder.String()
+}
+
+// Fast-forwards the game by the time that passed between saving and now.
+// Returns nil if no time was simulated, for example because the save has
+// no timestamp.
+func (state *AppState) simulateOffline(now time.Time) *OfflineSummary {
+	if state.savedAt.IsZero() {
+		return nil
+	}
+	duration := now.Sub(state.savedAt)
+	if duration > MaxOfflineTime {
+		duration = MaxOfflineTime
+	}
+	ticks := int(duration / GameSpeed)
+	if ticks <= 0 {
+		return nil
+	}
+
+	summary := &OfflineSummary{
+		Events: map[string]int{},
+	}
+	moneyBefore, _ := state.Money.Get()
+	foodBefore, _ := state.Food.Get()
+
+	stop := state.countEvents(summary.Events)
+	summary.Ticks = state.runTicks(ticks, true)
+	stop()
+
+	moneyAfter, _ := state.Money.Get()
+	foodAfter, _ := state.Food.Get()
+	summary.Duration = time.Duration(summary.Ticks) * GameSpeed
+	summary.MoneyChange = moneyAfter - moneyBefore
+	summary.FoodChange = foodAfter - foodBefore
+	if summary.Ticks == 0 {
+		return nil
+	}
+	return summary
+}
+
+// Counts how often each event fires in counts until the returned function
+// is called
+func (state *AppState) countEvents(counts map[string]int) (stop func()) {
+	// buttons in the UI run events while the game ticks
+	var mu sync.Mutex
+	state.eventHook = func(event *Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[event.Name]++
+	}
+	return func() {
+		state.eventHook = nil
+	}
+}
+
+// Runs up to n game ticks as fast as possible without waiting for the UI.
+// Stops early when the game is paused, when a choice event is waiting for
+// the player, or when the game is over. With keepAlive it also stops
+// right before the player would starve.
+// Returns the number of ticks that were run.
+func (state *AppState) runTicks(n int, keepAlive bool) int {
+	for i := range n {
+		paused, err := state.Paused.Get()
+		if err != nil || paused {
+			return i
+		}
+		choiceEventName, err := state.ChoiceEventName.Get()
+		if err != nil || choiceEventName != "" {
+			return i
+		}
+		gameOver, err := state.GameOver.Get()
+		if err != nil || gameOver {
+			return i
+		}
+		if keepAlive {
+			food, err := state.Food.Get()
+			if err != nil {
+				return i
+			}
+			eventName, err := state.ProgressEventName.Get()
+			if err != nil {
+				return i
+			}
+			if food <= 0 && eventName != "Sleeping" {
+				return i
+			}
+		}
+		state.gameTick()
+	}
+	return n
+}
@@ -0,0 +1,90 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// -----------------------------
+// Tests for offline progress
+// -----------------------------
+
+func TestSimulateOffline(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	// no progress for new games
+	if summary := state.simulateOffline(time.Now()); summary != nil {
+		t.Errorf("Expected no offline progress without savedAt, got %+v", summary)
+	}
+
+	state.Events = []Event{
+		NewEvent("Paycheck", func() bool { return true }, func() bool {
+			state.Set("money", state.Get("money").(int)+1)
+			return false
+		}, nil),
+	}
+	now := time.Now()
+	state.savedAt = now.Add(-10 * time.Second)
+
+	summary := state.simulateOffline(now)
+	if summary == nil {
+		t.Fatalf("Expected offline progress")
+	}
+	if summary.Ticks != 100 {
+		t.Errorf("Expected 100 ticks, got %d", summary.Ticks)
+	}
+	checkBindingInt(t, state.Ticks, 100)
+	if summary.MoneyChange != 100 {
+		t.Errorf("Expected money change of 100, got %d", summary.MoneyChange)
+	}
+	if summary.FoodChange != -100 {
+		t.Errorf("Expected food change of -100, got %d", summary.FoodChange)
+	}
+	if summary.Events["Paycheck"] != 100 {
+		t.Errorf("Expected Paycheck to fire 100 times, got %d", summary.Events["Paycheck"])
+	}
+}
+
+func TestSimulateOfflineStopsBeforeStarving(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.Events = []Event{}
+	state.Food.Set(50)
+	now := time.Now()
+	state.savedAt = now.Add(-time.Hour)
+
+	summary := state.simulateOffline(now)
+	if summary == nil || summary.Ticks != 50 {
+		t.Fatalf("Expected 50 ticks before running out of food, got %+v", summary)
+	}
+	checkBindingInt(t, state.Food, 0)
+}
+
+func TestSimulateOfflineStopsAtChoice(t *testing.T) {
+	// the choice event doesn't pause the game
+	state := newScriptedState(t, `=== Pick a job
+? ticks >= 10
+* Retail -> Chose Retail
+* Office -> Chose Office
+
+=== Chose Retail
+! job = Sales clerk
+
+=== Chose Office
+! job = Office clerk`)
+	state.Food.Set(200)
+	now := time.Now()
+	state.savedAt = now.Add(-time.Hour)
+
+	summary := state.simulateOffline(now)
+	if summary == nil || summary.Ticks != 10 {
+		t.Fatalf("Expected 10 ticks before the choice, got %+v", summary)
+	}
+	checkBindingString(t, state.ChoiceEventName, "Pick a job")
+	checkBindingInt(t, state.Ticks, 10)
+}
@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+)
+
+// Leaves out the mods that override or patch an event that doesn't exist in
+// any of mods. modEvents holds the events of every mod by its name.
+func checkModifiedEvents(mods []Mod, modEvents map[string][]ScriptEvent) ([]Mod, ScriptErrors) {
+	events := map[string]bool{}
+	for _, mod := range mods {
+		for _, event := range modEvents[mod.Name] {
+			if event.Modifies == "" {
+				events[event.Name] = true
+			}
+		}
+	}
+
+	var checked []Mod
+	var errs ScriptErrors
+	for _, mod := range mods {
+		ok := true
+		for _, event := range modEvents[mod.Name] {
+			if event.Modifies != "" && !events[event.Name] {
+				errs = append(errs, &ScriptError{Mod: mod.Name, File: mod.Name, Message: fmt.Sprintf("%s of an event that does not exist: %s", event.Modifies, event.Name)})
+				ok = false
+			}
+		}
+		if ok {
+			checked = append(checked, mod)
+		}
+	}
+	return checked, errs
+}
+
+// Applies the overrides and patches in events to the events they change, in
+// the order the events were loaded in. An override replaces the event but
+// keeps its place in the order the events are checked in, so a later
+// override wins and patches loaded after an override change the replaced
+// event.
+func applyOverrides(events []ScriptEvent) []ScriptEvent {
+	var result []ScriptEvent
+	index := map[string]int{}
+	for _, event := range events {
+		if event.Modifies == "" {
+			index[event.Name] = len(result)
+			result = append(result, event)
+		}
+	}
+
+	for _, event := range events {
+		if event.Modifies == "" {
+			continue
+		}
+		i, ok := index[event.Name]
+		if !ok {
+			log.Printf("Error: %s of an event that does not exist: %s\n", event.Modifies, event.Name)
+			continue
+		}
+		switch event.Modifies {
+		case "override":
+			event.Modifies = ""
+			result[i] = event
+		case "patch":
+			result[i] = patchEvent(result[i], event)
+		}
+	}
+	return result
+}
+
+// Returns event with the conditions, actions, buttons and choices of patch
+// added to it. The cooldown, max and priority of the patch replace the ones
+// of the event if they are set.
+func patchEvent(event ScriptEvent, patch ScriptEvent) ScriptEvent {
+	event.ScriptConditions = append(slices.Clone(event.ScriptConditions), patch.ScriptConditions...)
+	event.ScriptActions = append(slices.Clone(event.ScriptActions), patch.ScriptActions...)
+	event.ScriptButtons = append(slices.Clone(event.ScriptButtons), patch.ScriptButtons...)
+	event.Choices = maps.Clone(event.Choices)
+	if event.Choices == nil {
+		event.Choices = map[string]Choice{}
+	}
+	// choices of the patch come after the ones of the event, unless they
+	// replace one
+	offset := len(event.Choices)
+	for _, choice := range orderedChoices(patch.Choices) {
+		if existing, ok := event.Choices[choice.ButtonText]; ok {
+			choice.Order = existing.Order
+		} else {
+			choice.Order = offset
+			offset++
+		}
+		event.Choices[choice.ButtonText] = choice
+	}
+	if patch.Cooldown != 0 {
+		event.Cooldown = patch.Cooldown
+	}
+	if patch.MaxRuns != 0 {
+		event.MaxRuns = patch.MaxRuns
+	}
+	if patch.Priority != 0 {
+		event.Priority = patch.Priority
+	}
+	return event
+}
@@ -0,0 +1,128 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// -----------------------------
+// Tests for overrides and patches
+// -----------------------------
+
+func TestParseOverrides(t *testing.T) {
+	scriptEvents, err := parseScript(`=== override default/Promotion to Manager
+? workxp == 2000
+! salary = 800
+> true
+
+=== patch default/Promotion to Manager
+? charisma > 10
+! print Well deserved!
+> false
+% 10
+
+=== A/B`)
+
+	if scriptEvents[0].Modifies != "override" || scriptEvents[0].Name != "default/Promotion to Manager" {
+		t.Errorf("Expected an override of default/Promotion to Manager, got %s %s", scriptEvents[0].Modifies, scriptEvents[0].Name)
+	}
+	if scriptEvents[1].Modifies != "patch" || len(scriptEvents[1].ScriptConditions) != 1 {
+		t.Errorf("Expected a patch with one condition, got %+v", scriptEvents[1])
+	}
+
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("Expected ScriptErrors, got %v", err)
+	}
+	expected := []struct {
+		line    int
+		message string
+	}{
+		{9, "> lines can't be used in a patch"},
+		{10, "% lines can't be used in a patch"},
+		{12, "event names can't contain /"},
+	}
+	if len(scriptErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(scriptErrors), scriptErrors)
+	}
+	for i, e := range expected {
+		if scriptErrors[i].Line != e.line || !strings.Contains(scriptErrors[i].Message, e.message) {
+			t.Errorf("Expected line %d: %s, got %s", e.line, e.message, scriptErrors[i])
+		}
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	parse := func(modName, script string) []ScriptEvent {
+		events, err := parseScript(script)
+		if err != nil {
+			t.Fatalf("Error parsing script: %s", err)
+		}
+		return prefixModName(events, modName)
+	}
+	var events []ScriptEvent
+	events = append(events, parse("default", `=== Raise
+? workxp == 1000
+! salary = 600
+> true
+
+=== Promotion to Manager
+? workxp == 1000
+! job = Manager
+> true`)...)
+	events = append(events, parse("harder", `=== patch default/Promotion to Manager
+? charisma > 10
+! print Well deserved!
+
+=== override default/Raise
+? workxp == 500
+! salary = 700`)...)
+	events = append(events, parse("generous", `=== override default/Raise
+? workxp == 100
+! salary = 900
+> true
+
+=== patch default/Raise
+! print Congratulations!`)...)
+
+	events = applyOverrides(events)
+	if len(events) != 2 || events[0].Name != "default/Raise" || events[1].Name != "default/Promotion to Manager" {
+		t.Fatalf("Expected the events to keep their names and order, got %v", events)
+	}
+
+	raise := events[0]
+	if len(raise.ScriptConditions) != 1 || len(raise.ScriptActions) != 2 || !raise.Return {
+		t.Errorf("Expected the last override with the patch after it, got %+v", raise)
+	}
+	if raise.ScriptActions[0].Value.(Expr).String() != "900" {
+		t.Errorf("Expected the salary from the last override, got %v", raise.ScriptActions[0].Value)
+	}
+
+	promotion := events[1]
+	if len(promotion.ScriptConditions) != 2 || len(promotion.ScriptActions) != 2 || !promotion.Return {
+		t.Errorf("Expected the patch to add a condition and an action, got %+v", promotion)
+	}
+}
+
+func TestModifyMissingEvent(t *testing.T) {
+	mods := []Mod{{Name: "default"}, {Name: "broken"}}
+	modEvents := map[string][]ScriptEvent{
+		"default": {{Name: "default/Raise"}},
+		"broken":  {{Name: "default/Demotion", Modifies: "patch"}},
+	}
+
+	checked, errs := checkModifiedEvents(mods, modEvents)
+	if len(checked) != 1 || checked[0].Name != "default" {
+		t.Errorf("Expected only the default mod to be kept, got %v", checked)
+	}
+	if len(errs) != 1 || errs[0].Message != "patch of an event that does not exist: default/Demotion" {
+		t.Errorf("Expected an error about the missing event, got %v", errs)
+	}
+}
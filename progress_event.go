@@ -9,72 +9,243 @@ package main
 
 import (
 	"fmt"
-
-	"fyne.io/fyne/v2/data/binding"
+	"slices"
 )
 
 type ProgressEvent struct {
 	state *AppState
 }
 
-func (e *ProgressEvent) newEventWith(eventName string, doneMessage string, eventMax int, onDone func(), onTick func()) {
+// Starts a progress event, or queues it if another one is running. An
+// event that is already running or queued isn't queued again.
+func (e *ProgressEvent) newEventWith(activity *progressHandlers) {
 	currentEventName, err := e.state.ProgressEventName.Get()
 	if err != nil {
 		fmt.Println("Error getting event name:", err)
 		return
 	}
-	if currentEventName != "" {
-		// Already in an event, so just return
+	if currentEventName == "" {
+		e.begin(activity)
+		return
+	}
+	if currentEventName == activity.name || slices.Contains(e.queuedNames(), activity.name) {
 		return
 	}
+	e.state.queue = append(e.state.queue, activity)
+	e.updateQueue()
+}
+
+// The handlers of a running or queued progress event
+type progressHandlers struct {
+	name        string
+	doneMessage string
+	eventMax    int
+	onDone      func()
+	onTick      func()
+}
 
+// Starts a progress event from the beginning
+func (e *ProgressEvent) begin(activity *progressHandlers) {
 	e.state.Working.Set(false)
-	e.state.ProgressEventName.Set(eventName)
+	e.state.ProgressEventName.Set(activity.name)
 	e.state.ProgressEventValue.Set(0)
-	e.state.ProgressEventMax.Set(eventMax)
-	var listener binding.DataListener
-	listener = binding.NewDataListener(func() {
-		eventValue, err := e.state.ProgressEventValue.Get()
-		if err != nil {
-			fmt.Println("Error getting event value:", err)
-			return
+	e.state.ProgressEventMax.Set(activity.eventMax)
+	e.start(activity)
+}
+
+// Registers the handlers for the current progress event. The event is
+// advanced synchronously by gameTick, so many ticks can be run quickly
+// without waiting for binding listeners.
+func (e *ProgressEvent) start(activity *progressHandlers) {
+	e.state.progress = activity
+	e.advance()
+}
+
+// Runs the tick handler of the current progress event and finishes the
+// event once its value reaches the maximum
+func (e *ProgressEvent) advance() {
+	progress := e.state.progress
+	if progress == nil {
+		return
+	}
+	eventValue, err := e.state.ProgressEventValue.Get()
+	if err != nil {
+		fmt.Println("Error getting event value:", err)
+		return
+	}
+	if progress.onTick != nil {
+		progress.onTick()
+	}
+	if eventValue >= progress.eventMax {
+		e.stop()
+		if progress.doneMessage != "" {
+			e.state.Messages.Prepend(progress.doneMessage)
 		}
-		if onTick != nil {
-			onTick()
+		if progress.onDone != nil {
+			progress.onDone()
 		}
-		if eventValue >= eventMax {
-			e.state.ProgressEventValue.RemoveListener(listener)
-			e.state.ProgressEventName.Set("")
-			e.state.Working.Set(true)
-			if doneMessage != "" {
-				e.state.Messages.Prepend(doneMessage)
-			}
-			if onDone != nil {
-				onDone()
-			}
+		e.startNext()
+	}
+}
+
+// Ends the current progress event without running its done handler
+func (e *ProgressEvent) stop() {
+	e.state.progress = nil
+	e.state.ProgressEventName.Set("")
+	e.state.Working.Set(true)
+}
+
+// Starts the next queued progress event if none is running
+func (e *ProgressEvent) startNext() {
+	if e.state.progress != nil || len(e.state.queue) == 0 {
+		return
+	}
+	next := e.state.queue[0]
+	e.state.queue = e.state.queue[1:]
+	e.updateQueue()
+	e.begin(next)
+}
+
+// Cancels the running or a queued progress event called name. Returns
+// false if there is no such progress event.
+func (e *ProgressEvent) cancel(name string) bool {
+	if e.state.progress != nil && e.state.progress.name == name {
+		e.stop()
+		e.state.Messages.Prepend(fmt.Sprintf("You stopped %s.", getStringAfterSlash(name)))
+		e.startNext()
+		return true
+	}
+	for i, activity := range e.state.queue {
+		if activity.name == name {
+			e.state.queue = slices.Delete(e.state.queue, i, i+1)
+			e.updateQueue()
+			return true
 		}
-	})
-	e.state.ProgressEventValue.AddListener(listener)
+	}
+	return false
 }
 
-func (e *ProgressEvent) MorningRoutine() {
+// Returns the names of the queued progress events in order
+func (e *ProgressEvent) queuedNames() []string {
+	names := make([]string, 0, len(e.state.queue))
+	for _, activity := range e.state.queue {
+		names = append(names, activity.name)
+	}
+	return names
+}
+
+// Shows the queued progress events in the UI
+func (e *ProgressEvent) updateQueue() {
+	e.state.QueuedEvents.Set(e.queuedNames())
+}
+
+// Returns the handlers of the progress event called name, or nil if there
+// is no such progress event, for example because its mod was removed
+func (e *ProgressEvent) activity(name string) *progressHandlers {
+	switch name {
+	case "Sleeping":
+		return &progressHandlers{name, sleepDoneMessage, 100, e.MorningRoutine, e.sleepTick}
+	case "Morning Routine":
+		bonus, ticksNeeded := e.morningRoutine()
+		return &progressHandlers{name, morningRoutineDoneMessage, ticksNeeded, func() {
+			e.state.RoutineBonus.Set(bonus)
+		}, nil}
+	default:
+		event := e.state.GetEvent(name)
+		if event == nil || event.OnProgressDone == nil {
+			return nil
+		}
+		return &progressHandlers{name, "", event.ProgressMax, event.OnProgressDone, nil}
+	}
+}
+
+// Continues a progress event that was running when the game was saved.
+// Only the name of the event is stored in a save, so the done and tick
+// handlers are looked up again by name.
+func (e *ProgressEvent) resume() {
+	eventName, err := e.state.ProgressEventName.Get()
+	if err != nil {
+		fmt.Println("Error getting event name:", err)
+		return
+	}
+	e.state.progress = nil
+	if eventName == "" {
+		return
+	}
+	eventMax, err := e.state.ProgressEventMax.Get()
+	if err != nil {
+		fmt.Println("Error getting event max:", err)
+		return
+	}
+	activity := e.activity(eventName)
+	if activity == nil {
+		// the event doesn't exist anymore, so just end it
+		fmt.Println("Unknown progress event:", eventName)
+		e.stop()
+		e.startNext()
+		return
+	}
+	// keep the maximum the event was started with
+	activity.eventMax = eventMax
+	e.start(activity)
+}
+
+// Replaces the queued progress events with the ones called names, as
+// stored in a save
+func (e *ProgressEvent) setQueue(names []string) {
+	e.state.queue = nil
+	for _, name := range names {
+		activity := e.activity(name)
+		if activity == nil {
+			fmt.Println("Unknown progress event:", name)
+			continue
+		}
+		e.state.queue = append(e.state.queue, activity)
+	}
+	e.updateQueue()
+	e.startNext()
+}
+
+// Looks up the handlers of the running and queued progress events again
+// after the events were reloaded, so they don't run the actions of the
+// old scripts
+func (e *ProgressEvent) reload() {
+	if progress := e.state.progress; progress != nil {
+		activity := e.activity(progress.name)
+		if activity == nil {
+			fmt.Println("Unknown progress event:", progress.name)
+			e.stop()
+		} else {
+			activity.eventMax = progress.eventMax
+			e.state.progress = activity
+		}
+	}
+	e.setQueue(e.queuedNames())
+}
+
+const (
+	sleepDoneMessage          = "You slept well and feel refreshed."
+	morningRoutineDoneMessage = "You completed your morning routine."
+)
+
+// Returns the appearance bonus for the selected morning routine tasks
+// and the ticks needed to complete them
+func (e *ProgressEvent) morningRoutine() (bonus int, ticksNeeded int) {
 	routineShower, err := e.state.RoutineShower.Get()
 	if err != nil {
 		fmt.Println("Error getting routine shower:", err)
-		return
+		return 0, 0
 	}
 	routineShave, err := e.state.RoutineShave.Get()
 	if err != nil {
 		fmt.Println("Error getting routine shave:", err)
-		return
+		return 0, 0
 	}
 	routineBrushTeeth, err := e.state.RoutineBrushTeeth.Get()
 	if err != nil {
 		fmt.Println("Error getting routine brush teeth:", err)
-		return
+		return 0, 0
 	}
-	ticksNeeded := 0
-	bonus := 0
 	if routineShower {
 		ticksNeeded += 20
 		bonus += 10
@@ -87,41 +258,32 @@ func (e *ProgressEvent) MorningRoutine() {
 		ticksNeeded += 5
 		bonus += 2
 	}
-	e.newEventWith(
-		"Morning Routine",
-		"You completed your morning routine.",
-		ticksNeeded,
-		func() {
-			e.state.RoutineBonus.Set(bonus)
-		},
-		nil,
-	)
+	return bonus, ticksNeeded
+}
+
+func (e *ProgressEvent) MorningRoutine() {
+	e.newEventWith(e.activity("Morning Routine"))
 }
 
 func (e *ProgressEvent) Sleep() {
-	e.newEventWith(
-		"Sleeping",
-		"You slept well and feel refreshed.",
-		100,
-		func() {
-			e.MorningRoutine()
-		},
-		func() {
-			energy, err := e.state.Energy.Get()
-			if err != nil {
-				fmt.Println("Error getting energy:", err)
-				return
-			}
-			energyMax, err := e.state.EnergyMax.Get()
-			if err != nil {
-				fmt.Println("Error getting energy max:", err)
-				return
-			}
-			if energy < energyMax {
-				e.state.Energy.Set(energy + 1)
-			}
-		},
-	)
+	e.newEventWith(e.activity("Sleeping"))
+}
+
+// Restores one point of energy per tick while sleeping
+func (e *ProgressEvent) sleepTick() {
+	energy, err := e.state.Energy.Get()
+	if err != nil {
+		fmt.Println("Error getting energy:", err)
+		return
+	}
+	energyMax, err := e.state.EnergyMax.Get()
+	if err != nil {
+		fmt.Println("Error getting energy max:", err)
+		return
+	}
+	if energy < energyMax {
+		e.state.Energy.Set(energy + 1)
+	}
 }
 
 func NewEventHandler(appstate *AppState) *ProgressEvent {
@@ -0,0 +1,93 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"testing"
+)
+
+// -----------------------------
+// Tests for the progress event queue
+// -----------------------------
+
+func newQueueState(t *testing.T) *AppState {
+	state := newScriptedState(t, `=== Reading
+? true
+% 3
+! order = order + "R"
+> true
+
+=== Cooking
+? true
+% 2
+! order = order + "C"
+> true
+
+=== Cleaning
+? true
+% 2
+! order = order + "L"
+> true`)
+	state.Set("order", "")
+	return state
+}
+
+func TestProgressEventQueue(t *testing.T) {
+	state := newQueueState(t)
+
+	state.gameTick()
+	checkBindingString(t, state.ProgressEventName, "Reading")
+	checkBindingStringList(t, state.QueuedEvents, []string{"Cooking", "Cleaning"})
+
+	for range 10 {
+		state.gameTick()
+	}
+	if order := state.Get("order"); order != "RCL" {
+		t.Errorf("Expected the progress events to finish in the order RCL, got %v", order)
+	}
+	checkBindingString(t, state.ProgressEventName, "")
+	checkBindingStringList(t, state.QueuedEvents, []string{})
+}
+
+func TestCancelProgressEvent(t *testing.T) {
+	state := newQueueState(t)
+	state.gameTick()
+
+	state.CancelProgressEvent("Cleaning")
+	checkBindingStringList(t, state.QueuedEvents, []string{"Cooking"})
+
+	state.CancelProgressEvent("Reading")
+	checkBindingString(t, state.ProgressEventName, "Cooking")
+	checkBindingStringList(t, state.QueuedEvents, []string{})
+
+	for range 10 {
+		state.gameTick()
+	}
+	if order := state.Get("order"); order != "C" {
+		t.Errorf("Expected only Cooking to finish, got %v", order)
+	}
+}
+
+func TestQueueSurvivesSaving(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	NewEventHandler(state).Sleep()
+	NewEventHandler(state).MorningRoutine()
+	checkBindingStringList(t, state.QueuedEvents, []string{"Morning Routine"})
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	loaded, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+
+	checkBindingString(t, loaded.ProgressEventName, "Sleeping")
+	checkBindingStringList(t, loaded.QueuedEvents, []string{"Morning Routine"})
+}
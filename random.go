@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+)
+
+// Random is the random number generator of a game. The same seed always
+// gives the same numbers, so a game can be replayed from its seed.
+//
+// Instead of one sequence of numbers that all events take from, every
+// event gets its own sequence derived from the seed, the tick and the
+// event name, so adding or removing an event doesn't change the random
+// numbers of all the others.
+type Random struct {
+	seed uint64
+
+	mu sync.Mutex
+	// sequence for random numbers that are not drawn by an event, it
+	// starts over every tick
+	shared     *rand.Rand
+	sharedTick int
+}
+
+// Creates a Random with the given seed, 0 picks a random seed
+func NewRandom(seed uint64) *Random {
+	for seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Random{seed: seed}
+}
+
+// Returns the seed of the generator
+func (r *Random) Seed() uint64 {
+	return r.seed
+}
+
+// Returns the sequence of random numbers for the n-th run of the event
+// called name at tick
+func (r *Random) For(tick int, name string, n int) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	binary.Write(h, binary.LittleEndian, [2]int64{int64(tick), int64(n)})
+	return rand.New(rand.NewPCG(r.seed, h.Sum64()))
+}
+
+// Returns a random number between 0 and 1 that isn't drawn by an event
+func (r *Random) Float64(tick int) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shared == nil || r.sharedTick != tick {
+		r.shared = r.For(tick, "", 0)
+		r.sharedTick = tick
+	}
+	return r.shared.Float64()
+}
+
+// Replaces the random numbers of the game with the ones of seed
+func (state *AppState) SetSeed(seed uint64) {
+	state.random = NewRandom(seed)
+}
+
+// Seeds are stored as strings in saves, JSON numbers can't hold every
+// uint64
+func formatSeed(seed uint64) string {
+	return strconv.FormatUint(seed, 10)
+}
+
+func parseSeed(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
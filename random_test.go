@@ -0,0 +1,140 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// -----------------------------
+// Tests for seeded random numbers
+// -----------------------------
+
+// Plays 150 ticks of a game that flips coins with the given seed and
+// returns its variables
+func playCoinFlips(t *testing.T, seed uint64) map[string]any {
+	state := newScriptedState(t, `=== Flip
+? rand < 0.5
+random
+1: heads += 1
+1: tails += 1
+end
+
+=== Lucky
+? roll < 0.1
+! lucky += 1`)
+	state.SetSeed(seed)
+	for _, name := range []string{"heads", "tails", "lucky"} {
+		state.Set(name, 0)
+	}
+	for range 150 {
+		state.gameTick()
+	}
+
+	variables := map[string]any{}
+	for _, name := range []string{"heads", "tails", "lucky"} {
+		variables[name] = state.Get(name)
+	}
+	return variables
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+	first := playCoinFlips(t, 42)
+	second := playCoinFlips(t, 42)
+	for name, value := range first {
+		if second[name] != value {
+			t.Errorf("Expected %s to be %v with the same seed, got %v", name, value, second[name])
+		}
+	}
+	if first["heads"].(int)+first["tails"].(int) == 0 {
+		t.Errorf("Expected some coin flips, got %v", first)
+	}
+
+	other := playCoinFlips(t, 43)
+	if other["heads"] == first["heads"] && other["tails"] == first["tails"] && other["lucky"] == first["lucky"] {
+		t.Errorf("Expected a different game with a different seed, got %v both times", first)
+	}
+}
+
+// Plays a game with a choice event with the given seed, picking random
+// choices, and returns the offered choices and the job that was picked
+func playChoices(t *testing.T, seed uint64) ([]string, any) {
+	script := "=== Pick a job\n? true\n! paused = true\n"
+	for _, job := range []string{"Retail", "Office", "Factory", "Farm", "Shop"} {
+		script += "* " + job + " -> Chose " + job + "\n"
+	}
+	script += "> true\n"
+	for _, job := range []string{"Retail", "Office", "Factory", "Farm", "Shop"} {
+		script += "\n=== Chose " + job + "\n! job = " + job + "\n! paused = false\n> true\n"
+	}
+	state := newScriptedState(t, script)
+	state.SetSeed(seed)
+	random, err := NewChoiceStrategy("random", "", seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offered []string
+	strategy := func(eventName string, choices []string) string {
+		offered = append(offered, choices...)
+		return random(eventName, choices)
+	}
+	report := state.simulate(10, strategy)
+	return append(offered, report.Choices...), state.Get("job")
+}
+
+func TestSameSeedSameChoices(t *testing.T) {
+	expected := []string{"Retail", "Office", "Factory", "Farm", "Shop"}
+	first, firstJob := playChoices(t, 42)
+	if !slices.Equal(first[:len(expected)], expected) {
+		t.Errorf("Expected the choices in script order %v, got %v", expected, first)
+	}
+	// map order changes from run to run, so play a few times
+	for range 10 {
+		choices, job := playChoices(t, 42)
+		if !slices.Equal(choices, first) || job != firstJob {
+			t.Fatalf("Expected %v and job %v with the same seed, got %v and %v", first, firstJob, choices, job)
+		}
+	}
+}
+
+func TestSeedSurvivesSaving(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.SetSeed(12345678901234567890)
+	state.Ticks.Set(500)
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	loaded, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+	if seed := loaded.random.Seed(); seed != 12345678901234567890 {
+		t.Fatalf("Expected seed 12345678901234567890, got %d", seed)
+	}
+	for range 3 {
+		if expected, got := state.Get("rand"), loaded.Get("rand"); got != expected {
+			t.Errorf("Expected rand to continue with %v after loading, got %v", expected, got)
+		}
+	}
+}
+
+func TestOldSaveGetsSeed(t *testing.T) {
+	loaded, err := fromJSON(`{"version": 4, "ticks": 10}`)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+	if loaded == nil {
+		t.Fatalf("Expected appState to be non-nil")
+	}
+	if loaded.random.Seed() == 0 {
+		t.Errorf("Expected a new seed for a save without one")
+	}
+}
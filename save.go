@@ -0,0 +1,237 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Returns the path of the saves folder, creating it if it doesn't exist yet
+func savesDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine user home directory: %w", err)
+	}
+	savePath := filepath.Join(homeDir, "Documents", "IdleYou", "saves")
+	err = os.MkdirAll(savePath, 0755)
+	if err != nil {
+		return "", fmt.Errorf("could not create saves folder: %w", err)
+	}
+	return savePath, nil
+}
+
+// Writes the state to a new save file in dir and returns the path of the file.
+// Save files are named after the time they were written, so they never
+// overwrite each other.
+func writeSave(dir string, state *AppState) (string, error) {
+	jsonData, err := state.toJSON()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("save_%s.json", time.Now().Format("20060102_150405.000"))
+	path := filepath.Join(dir, name)
+	err = writeFileAtomic(path, []byte(jsonData))
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Writes autosaves to a fixed number of slots, always overwriting the oldest
+// one, so a single broken write never loses all progress
+type Autosaver struct {
+	dir      string
+	interval int
+	slots    int
+	lastTick int
+}
+
+// Creates an Autosaver that saves every interval ticks into dir.
+// An interval of 0 disables saving on ticks.
+func NewAutosaver(dir string, interval int, slots int) *Autosaver {
+	if slots < 1 {
+		slots = 1
+	}
+	return &Autosaver{
+		dir:      dir,
+		interval: interval,
+		slots:    slots,
+		lastTick: -1,
+	}
+}
+
+// Saves the game if interval ticks have passed since the last autosave.
+// Should be called after every gameTick.
+func (a *Autosaver) Tick(state *AppState) {
+	if a.interval <= 0 {
+		return
+	}
+	ticks, err := state.Ticks.Get()
+	if err != nil {
+		fmt.Println("Error getting ticks:", err)
+		return
+	}
+	if a.lastTick < 0 || ticks < a.lastTick {
+		// don't save right after starting or loading the game
+		a.lastTick = ticks
+		return
+	}
+	if ticks-a.lastTick < a.interval {
+		return
+	}
+	a.lastTick = ticks
+	_, err = a.Save(state)
+	if err != nil {
+		fmt.Println("Error autosaving:", err)
+	}
+}
+
+// Writes the state to the next autosave slot and returns the path of the file
+func (a *Autosaver) Save(state *AppState) (string, error) {
+	jsonData, err := state.toJSON()
+	if err != nil {
+		return "", err
+	}
+	path := a.nextSlot()
+	err = writeFileAtomic(path, []byte(jsonData))
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Returns the path of the first unused slot or the slot that was written
+// the longest time ago
+func (a *Autosaver) nextSlot() string {
+	var oldestPath string
+	var oldestTime time.Time
+	for i := 1; i <= a.slots; i++ {
+		path := filepath.Join(a.dir, fmt.Sprintf("autosave_%d.json", i))
+		info, err := os.Stat(path)
+		if err != nil {
+			return path
+		}
+		if oldestPath == "" || info.ModTime().Before(oldestTime) {
+			oldestPath = path
+			oldestTime = info.ModTime()
+		}
+	}
+	return oldestPath
+}
+
+// Writes data to a temporary file first and then renames it, so a crash
+// during the write never leaves a half written save behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	err := os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Returns the save files in dir, newest first
+func listSaves(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type saveFile struct {
+		path    string
+		modTime time.Time
+	}
+	var saves []saveFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		saves = append(saves, saveFile{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	sort.SliceStable(saves, func(i, j int) bool {
+		if saves[i].modTime.Equal(saves[j].modTime) {
+			return saves[i].path > saves[j].path
+		}
+		return saves[i].modTime.After(saves[j].modTime)
+	})
+	paths := make([]string, 0, len(saves))
+	for _, save := range saves {
+		paths = append(paths, save.path)
+	}
+	return paths, nil
+}
+
+// Reads a save file and creates a new AppState from it
+func loadSave(path string) (*AppState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := fromJSON(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid save file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Loads the most recent save file in dir.
+// Returns os.ErrNotExist if there are no saves yet.
+func loadLatestSave(dir string) (*AppState, error) {
+	saves, err := listSaves(dir)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, path := range saves {
+		state, err := loadSave(path)
+		if err == nil {
+			return state, nil
+		}
+		// fall back to older saves if the newest one is broken
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, os.ErrNotExist
+}
+
+// Loads the latest save if there is one, otherwise starts a new game with
+// seed
+func loadLatestSaveOrDefaults(seed uint64) *AppState {
+	newGame := func() *AppState {
+		state := NewAppStateWithDefaults()
+		state.SetSeed(seed)
+		return state
+	}
+	dir, err := savesDir()
+	if err != nil {
+		fmt.Println("Error finding saves:", err)
+		return newGame()
+	}
+	state, err := loadLatestSave(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Error loading save:", err)
+		}
+		return newGame()
+	}
+	if seed != 0 {
+		fmt.Println("Continuing the saved game with its own seed", formatSeed(state.random.Seed()))
+	}
+	return state
+}
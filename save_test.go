@@ -0,0 +1,126 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// -----------------------------
+// Tests for save files
+// -----------------------------
+
+func TestWriteSaveLoadLatestSave(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := loadLatestSave(dir)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist for empty saves folder, got %v", err)
+	}
+
+	state := NewAppStateWithDefaults()
+	state.Money.Set(1234)
+	state.Set("myVariable", 42)
+	_, err = writeSave(dir, state)
+	if err != nil {
+		t.Fatalf("Error writing save: %s", err)
+	}
+
+	loaded, err := loadLatestSave(dir)
+	if err != nil {
+		t.Fatalf("Error loading save: %s", err)
+	}
+	checkBindingInt(t, loaded.Money, 1234)
+	if loaded.Get("myVariable") != 42 {
+		t.Errorf("Expected myVariable to be 42, got %v", loaded.Get("myVariable"))
+	}
+}
+
+func TestLoadLatestSaveSkipsBrokenSaves(t *testing.T) {
+	dir := t.TempDir()
+
+	state := NewAppStateWithDefaults()
+	state.Money.Set(500)
+	path, err := writeSave(dir, state)
+	if err != nil {
+		t.Fatalf("Error writing save: %s", err)
+	}
+
+	// a newer, broken save
+	brokenPath := filepath.Join(dir, "save_broken.json")
+	err = os.WriteFile(brokenPath, []byte(`{"ticks": `), 0644)
+	if err != nil {
+		t.Fatalf("Error writing broken save: %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(brokenPath, info.ModTime(), info.ModTime().Add(1e9))
+
+	saves, err := listSaves(dir)
+	if err != nil {
+		t.Fatalf("Error listing saves: %s", err)
+	}
+	if len(saves) != 2 || saves[0] != brokenPath {
+		t.Fatalf("Expected broken save to be listed first, got %v", saves)
+	}
+
+	loaded, err := loadLatestSave(dir)
+	if err != nil {
+		t.Fatalf("Error loading save: %s", err)
+	}
+	checkBindingInt(t, loaded.Money, 500)
+}
+
+func TestAutosaverRotatesSlots(t *testing.T) {
+	dir := t.TempDir()
+	autosaver := NewAutosaver(dir, 10, 2)
+	state := NewAppStateWithDefaults()
+
+	// the first tick only remembers the current tick
+	autosaver.Tick(state)
+	state.Ticks.Set(5)
+	autosaver.Tick(state)
+	saves, _ := listSaves(dir)
+	if len(saves) != 0 {
+		t.Fatalf("Expected no autosave before the interval, got %v", saves)
+	}
+
+	state.Ticks.Set(10)
+	autosaver.Tick(state)
+	saves, _ = listSaves(dir)
+	if len(saves) != 1 || filepath.Base(saves[0]) != "autosave_1.json" {
+		t.Fatalf("Expected autosave_1.json, got %v", saves)
+	}
+
+	// the next saves use the second slot and then overwrite the oldest one
+	second, err := autosaver.Save(state)
+	if err != nil {
+		t.Fatalf("Error autosaving: %s", err)
+	}
+	if filepath.Base(second) != "autosave_2.json" {
+		t.Errorf("Expected autosave_2.json, got %s", second)
+	}
+	first := filepath.Join(dir, "autosave_1.json")
+	info, err := os.Stat(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(first, info.ModTime(), info.ModTime().Add(-1e9))
+	third, err := autosaver.Save(state)
+	if err != nil {
+		t.Fatalf("Error autosaving: %s", err)
+	}
+	if third != first {
+		t.Errorf("Expected oldest slot %s to be overwritten, got %s", first, third)
+	}
+}
@@ -9,7 +9,9 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"maps"
+	"math/rand/v2"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -27,22 +29,33 @@ Simple scripting syntax for events
 Where lines starting with ? get turned into conditions and
 lines starting with ! into actions for the event.
 > true/false is the return value of the event, true marks it as done
+
+Actions and button changes can be wrapped in if blocks:
+
+if money > 500
+! print You bought a new TV.
+! money -= 500
+else if money > 100
+! print You bought a radio.
+else
+! print You can't afford anything.
+end
 */
 
 // -----------------------------
 // Script Types
 // -----------------------------
 
+// ScriptCondition is an expression that has to be true, like
+// "mood < 10 or not working"
 type ScriptCondition struct {
-	Variable string
-	Operator string
-	Value    interface{} // string, float64, int, or bool
+	Expr Expr
 }
 
 type ScriptAction struct {
 	Variable string
 	Operator string
-	Value    interface{} // string, float64, int, or bool
+	Value    interface{} // string for print and show, an Expr otherwise
 }
 
 type ScriptEvent struct {
@@ -52,7 +65,17 @@ type ScriptEvent struct {
 	ScriptButtons    []ScriptButton
 	Choices          map[string]Choice
 	ProgressMax      int
-	Return           bool
+	Schedule         *ScriptSchedule
+	// Minimum number of ticks between two runs, 0 for none
+	Cooldown int
+	// Maximum number of runs, 0 for no limit
+	MaxRuns int
+	// Events with a higher priority are checked first on every tick
+	Priority int
+	Return   bool
+	// "override" or "patch" if the event replaces or adds to the event
+	// called Name instead of being a new event, see applyOverrides
+	Modifies string
 }
 
 // ScriptButton represents a button addition/removal in a ScriptEvent.
@@ -63,10 +86,57 @@ type ScriptButton struct {
 	EventName  string
 }
 
+// ScriptSchedule runs an event from the timer queue instead of checking
+// its conditions on every tick. Kind is one of:
+//
+//	every - runs the event every Ticks ticks
+//	at    - runs the event once at tick Ticks
+//	after - runs the event Ticks ticks after EventName ran
+type ScriptSchedule struct {
+	Kind      string
+	Ticks     int
+	EventName string
+}
+
+// ScriptTrigger is the value of a trigger action, it runs the event after
+// Delay ticks
+type ScriptTrigger struct {
+	EventName string
+	Delay     int
+}
+
+// ScriptBranch is one branch of an if or random block.
+// In an if block the first branch whose Condition is true runs, an else
+// branch has no Condition. In a random block one branch runs, picked with
+// a chance of its Weight out of the total weight of all branches.
+// The blocks are stored as a ScriptAction with the Variable "if" or
+// "random" and the branches as the Value.
+type ScriptBranch struct {
+	Condition     *ScriptCondition
+	Weight        int
+	ScriptActions []ScriptAction
+	ScriptButtons []ScriptButton
+}
+
 type Choice struct {
 	ButtonText string
 	EventName  string
 	Conditions []ScriptCondition
+	// Position of the choice in the event, choices are offered in this order
+	Order int
+}
+
+// Returns the choices in the order they were written in the script, map
+// order is random and would make games with the same seed differ
+func orderedChoices(choices map[string]Choice) []Choice {
+	ordered := slices.Collect(maps.Values(choices))
+	slices.SortFunc(ordered, func(a, b Choice) int {
+		if a.Order != b.Order {
+			return a.Order - b.Order
+		}
+		return strings.Compare(a.ButtonText, b.ButtonText)
+	})
+	return ordered
 }
 
 // -----------------------------
@@ -75,39 +145,23 @@ type Choice struct {
 
 func scriptEventToEvent(state *AppState, scriptEvent ScriptEvent) Event {
 	isMultipleChoice := len(scriptEvent.Choices) > 0
+	scope := &eventScope{state: state, name: scriptEvent.Name}
 
 	// Build condition functions
 	var conditions []func() bool
 	for _, condition := range scriptEvent.ScriptConditions {
-		conditions = append(conditions, scriptConditionToFn(state, condition))
+		conditions = append(conditions, scriptConditionToFn(conditionScope{scope}, condition))
 	}
 
-	// Build action functions
-	var actions []func()
-	for _, action := range scriptEvent.ScriptActions {
-		actions = append(actions, scriptActionToFn(state, action, isMultipleChoice))
-	}
-
-	// append button addition and removals to actions
-	for _, button := range scriptEvent.ScriptButtons {
-		if button.EventName == "" {
-			actions = append(actions, func() {
-				state.RemoveButton(button.ButtonText)
-			})
-		} else {
-			actions = append(actions, func() {
-				state.AddButton(button.ButtonText, button.EventName)
-			})
-		}
-	}
+	runActions := scriptActionsToFn(state, scope, scriptEvent.ScriptActions, scriptEvent.ScriptButtons, isMultipleChoice)
 
 	event := NewEvent(
 		scriptEvent.Name,
 		func() bool {
 			// if no conditions are provided, return false so event
-			// is never triggered automatically
+			// is never triggered automatically, unless it is scheduled
 			if len(conditions) == 0 {
-				return false
+				return scriptEvent.Schedule != nil
 			}
 
 			for _, condition := range conditions {
@@ -118,37 +172,33 @@ func scriptEventToEvent(state *AppState, scriptEvent ScriptEvent) Event {
 			return true
 		},
 		func() bool {
-			// if it is a progress event
+			// if it is a progress event, start it or queue it if another
+			// one is running
 			if scriptEvent.ProgressMax > 0 {
-				// if no other event is running
-				if state.Get("eventName") == "" {
-					NewEventHandler(state).newEventWith(
-						scriptEvent.Name,
-						"",
-						scriptEvent.ProgressMax,
-						func() {
-							for _, action := range actions {
-								action()
-							}
-						},
-						nil,
-					)
-					return scriptEvent.Return
-				} else {
-					// an event is already running, so return false so that the event
-					// can be triggered again even if it is a one off event
-					return false
-				}
+				NewEventHandler(state).newEventWith(&progressHandlers{
+					name:     scriptEvent.Name,
+					eventMax: scriptEvent.ProgressMax,
+					onDone:   runActions,
+				})
+				return scriptEvent.Return
 			}
 
 			// if it's not a progress event
-			for _, action := range actions {
-				action()
-			}
+			runActions()
 			return scriptEvent.Return
 		},
 		scriptEvent.Choices,
 	)
+	if scriptEvent.ProgressMax > 0 {
+		event.OnProgressDone = runActions
+		event.ProgressMax = scriptEvent.ProgressMax
+	}
+	event.Schedule = scriptEvent.Schedule
+	event.Cooldown = scriptEvent.Cooldown
+	event.MaxRuns = scriptEvent.MaxRuns
+	event.Priority = scriptEvent.Priority
+	event.Start = scope.start
+	event.Variables = scope
 
 	return event
 }
@@ -157,7 +207,54 @@ func scriptEventToEvent(state *AppState, scriptEvent ScriptEvent) Event {
 // Script Action and Condition Functions
 // -----------------------------
 
-func scriptActionToFn(state *AppState, action ScriptAction, isMultipleChoice bool) func() {
+// Returns a function that runs the actions and then the button additions
+// and removals. Expressions in the actions are evaluated against vars.
+func scriptActionsToFn(state *AppState, vars Variables, scriptActions []ScriptAction, scriptButtons []ScriptButton, isMultipleChoice bool) func() {
+	// Build action functions
+	var actions []func()
+	for _, action := range scriptActions {
+		actions = append(actions, scriptActionToFn(state, vars, action, isMultipleChoice))
+	}
+
+	// append button addition and removals to actions
+	for _, button := range scriptButtons {
+		if button.EventName == "" {
+			actions = append(actions, func() {
+				state.RemoveButton(button.ButtonText)
+			})
+		} else {
+			actions = append(actions, func() {
+				state.AddButton(button.ButtonText, button.EventName)
+			})
+		}
+	}
+
+	return func() {
+		for _, action := range actions {
+			action()
+		}
+	}
+}
+
+func scriptActionToFn(state *AppState, vars Variables, action ScriptAction, isMultipleChoice bool) func() {
+	// Special case for if blocks
+	if action.Operator == "" && action.Variable == "if" {
+		return ifBlockToFn(state, vars, action.Value.([]ScriptBranch), isMultipleChoice)
+	}
+
+	// Special case for random blocks
+	if action.Operator == "" && action.Variable == "random" {
+		return randomBlockToFn(state, vars, action.Value.([]ScriptBranch), isMultipleChoice)
+	}
+
+	// Special case for trigger commands
+	if action.Operator == "" && action.Variable == "trigger" {
+		trigger := action.Value.(ScriptTrigger)
+		return func() {
+			state.Trigger(trigger.EventName, trigger.Delay)
+		}
+	}
+
 	// Special case for print commands
 	if action.Operator == "" && action.Variable == "print" {
 		return func() {
@@ -182,62 +279,339 @@ func scriptActionToFn(state *AppState, action ScriptAction, isMultipleChoice boo
 
 	// For other operations, use modifyState
 	return func() {
-		modifyState(state, action.Variable, action.Operator, action.Value)
+		modifyState(state, action.Variable, action.Operator, evalValue(vars, action.Value))
+	}
+}
+
+// Returns a function that runs the first branch of an if block whose
+// condition is true
+func ifBlockToFn(state *AppState, vars Variables, branches []ScriptBranch, isMultipleChoice bool) func() {
+	conditions := make([]func() bool, len(branches))
+	actions := make([]func(), len(branches))
+	for i, branch := range branches {
+		if branch.Condition != nil {
+			conditions[i] = scriptConditionToFn(vars, *branch.Condition)
+		}
+		actions[i] = scriptActionsToFn(state, vars, branch.ScriptActions, branch.ScriptButtons, isMultipleChoice)
+	}
+
+	return func() {
+		for i, condition := range conditions {
+			if condition == nil || condition() {
+				actions[i]()
+				return
+			}
+		}
+	}
+}
+
+// Returns a function that runs one branch of a random block, picked by
+// the weights of the branches
+func randomBlockToFn(state *AppState, vars Variables, branches []ScriptBranch, isMultipleChoice bool) func() {
+	totalWeight := 0
+	actions := make([]func(), len(branches))
+	for i, branch := range branches {
+		totalWeight += branch.Weight
+		actions[i] = scriptActionsToFn(state, vars, branch.ScriptActions, branch.ScriptButtons, isMultipleChoice)
+	}
+
+	return func() {
+		if totalWeight == 0 {
+			return
+		}
+		n := int(NewGameVariable("", vars.Get("rand")).Float64() * float64(totalWeight))
+		for i, branch := range branches {
+			if n < branch.Weight {
+				actions[i]()
+				return
+			}
+			n -= branch.Weight
+		}
 	}
 }
 
 func modifyState(state *AppState, actionVariable, actionOperator string, actionValue interface{}) {
 	variableGV := NewGameVariable(actionVariable, state.Get(actionVariable))
 	actionGV := NewGameVariable(actionVariable, actionValue)
+
+	var result GameVariable
 	switch actionOperator {
 	case "=":
-		actionGV.UpdateAppState(state)
+		result = actionGV
 	case "+=":
-		variableGV.Add(actionGV).UpdateAppState(state)
+		result = variableGV.Apply("+", actionGV)
 	case "-=":
-		variableGV.Subtract(actionGV).UpdateAppState(state)
+		result = variableGV.Apply("-", actionGV)
 	case "*=":
-		variableGV.Multiply(actionGV).UpdateAppState(state)
+		result = variableGV.Apply("*", actionGV)
 	case "/=":
-		variableGV.Divide(actionGV).UpdateAppState(state)
+		result = variableGV.Apply("/", actionGV)
 	default:
 		panic("Unsupported operator")
 	}
-}
 
-func scriptConditionToFn(state *AppState, condition ScriptCondition) func() bool {
-	// Special case for literal boolean conditions
-	if condition.Variable == "boolean" {
-		boolVal, ok := condition.Value.(bool)
-		if !ok {
-			panic("Invalid boolean value in condition")
+	// keep whole numbers whole, so "! food += foodMax * 0.1" and
+	// "! food = foodMax * 0.5" still result in an int. Custom variables
+	// take the type of the value they are set to with =.
+	isBuiltin := slices.Contains(builtinVariables, strings.ToLower(actionVariable))
+	if _, ok := variableGV.value.(int); ok && (actionOperator != "=" || isBuiltin) {
+		if f, ok := result.value.(float64); ok {
+			result = NewGameVariable(actionVariable, int(f))
 		}
-		return func() bool { return boolVal }
 	}
+	if err := result.UpdateAppState(state); err != nil {
+		fmt.Println("Error setting variable:", err)
+	}
+}
 
+func scriptConditionToFn(vars Variables, condition ScriptCondition) func() bool {
 	return func() bool {
-		// Retrieve the variable's current value from the state
-		variableValue := state.Get(condition.Variable)
+		return NewGameVariable("", condition.Expr.Eval(vars)).Bool()
+	}
+}
+
+// eventScope gives the conditions and actions of an event a shared roll,
+// a random number between 0 and 1 that is rolled again every time the
+// event is handled, and its own sequence of random numbers for rand.
+// Everything else comes from the AppState, or for the conditions of the
+// event from the snapshot it is checked against.
+type eventScope struct {
+	state    *AppState
+	snapshot Variables
+	name     string
+	rng      *rand.Rand
+	roll     float64
+	// tick of the last roll and how often the event was rolled in it
+	tick int
+	runs int
+}
+
+// Prepares the next time the event is handled: rolls its random numbers
+// and sets the variables its conditions are checked against, nil for the
+// AppState
+func (s *eventScope) start(snapshot Variables) {
+	s.snapshot = snapshot
+	tick, err := s.state.Ticks.Get()
+	if err != nil {
+		fmt.Println("Error getting ticks:", err)
+		return
+	}
+	if tick == s.tick {
+		s.runs++
+	} else {
+		s.tick, s.runs = tick, 0
+	}
+	s.rng = s.state.random.For(tick, s.name, s.runs)
+	s.roll = s.rng.Float64()
+}
+
+func (s *eventScope) Get(variable string) interface{} {
+	return s.get(s.state, variable)
+}
+
+// Returns roll and rand of the event, and everything else from vars
+func (s *eventScope) get(vars Variables, variable string) interface{} {
+	switch strings.ToLower(variable) {
+	case "roll":
+		return s.roll
+	case "rand":
+		if s.rng != nil {
+			return s.rng.Float64()
+		}
+	}
+	return vars.Get(variable)
+}
+
+// conditionScope is the view of an eventScope for the conditions of the
+// event, which read from the snapshot if there is one
+type conditionScope struct {
+	*eventScope
+}
+
+func (s conditionScope) Get(variable string) interface{} {
+	if s.snapshot != nil {
+		return s.get(s.snapshot, variable)
+	}
+	return s.get(s.state, variable)
+}
+
+// -----------------------------
+// Script Errors
+// -----------------------------
+
+// ScriptError is a problem found while parsing a script file.
+// Line and Column start at 1.
+type ScriptError struct {
+	Mod     string
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ScriptError) Error() string {
+	file := e.File
+	if file == "" {
+		file = "script"
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", file, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", file, e.Line, e.Column, e.Message)
+}
+
+// ScriptErrors collects all problems found in one pass over a script
+type ScriptErrors []*ScriptError
+
+func (e ScriptErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Returns a warning for the message log for every mod that was skipped
+// because of errs
+func skippedModWarnings(errs ScriptErrors) []string {
+	var modNames []string
+	modErrors := map[string]ScriptErrors{}
+	for _, err := range errs {
+		if _, ok := modErrors[err.Mod]; !ok {
+			modNames = append(modNames, err.Mod)
+		}
+		modErrors[err.Mod] = append(modErrors[err.Mod], err)
+	}
 
-		gameVariable := NewGameVariable(condition.Variable, variableValue)
+	warnings := make([]string, 0, len(modNames))
+	for _, modName := range modNames {
+		name := modName
+		if name == "" {
+			name = "(root)"
+		}
+		warnings = append(warnings, fmt.Sprintf("Mod %s was skipped because of errors:\n%v", name, modErrors[modName]))
+	}
+	return warnings
+}
+
+// syntaxError is returned by the line parsers, the column is relative to
+// the start of the string passed to the parser and gets turned into a
+// ScriptError with the position in the file by parseScript
+type syntaxError struct {
+	column  int
+	message string
+}
+
+func (e *syntaxError) Error() string {
+	return e.message
+}
+
+func newSyntaxError(column int, format string, args ...any) *syntaxError {
+	return &syntaxError{column, fmt.Sprintf(format, args...)}
+}
+
+// Moves the column of a syntaxError by offset, used when a parser was
+// given only part of a line
+func shiftError(err error, offset int) error {
+	if se, ok := err.(*syntaxError); ok {
+		return &syntaxError{se.column + offset, se.message}
+	}
+	return err
+}
+
+// A word in a line together with the column it starts at
+type field struct {
+	text   string
+	column int
+}
 
-		conditionGV := NewGameVariable(condition.Variable, condition.Value)
+// Like strings.Fields, but also returns the column of each field
+func fields(s string) []field {
+	var result []field
+	start := -1
+	for i, r := range s {
+		if r == ' ' || r == '\t' {
+			if start >= 0 {
+				result = append(result, field{s[start:i], start + 1})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		result = append(result, field{s[start:], start + 1})
+	}
+	return result
+}
 
-		return gameVariable.Compare(conditionGV, condition.Operator)
+// Joins the text of fields with a single space
+func joinFields(fs []field) string {
+	texts := make([]string, 0, len(fs))
+	for _, f := range fs {
+		texts = append(texts, f.text)
 	}
+	return strings.Join(texts, " ")
 }
 
+var (
+	conditionOperators = []string{"==", "!=", "<", ">", "<=", ">="}
+	actionOperators    = []string{"=", "+=", "-=", "*=", "/="}
+)
+
 // -----------------------------
 // Script Parsing Functions
 // -----------------------------
 
-func parseScript(script string) []ScriptEvent {
+// Parses a script into ScriptEvents. Lines with errors are skipped and all
+// errors are returned together as ScriptErrors, so a single pass reports
+// every problem in the script.
+func parseScript(script string) ([]ScriptEvent, error) {
 	lines := strings.Split(script, "\n")
 	var events []ScriptEvent
 	var currentEvent *ScriptEvent
+	var errs ScriptErrors
+	// if and random blocks of the current event that are still open,
+	// innermost last
+	var blocks []*openBlock
+
+	// Actions and button changes go into the innermost open block
+	addAction := func(action ScriptAction) {
+		if len(blocks) > 0 {
+			branch := blocks[len(blocks)-1].lastBranch()
+			branch.ScriptActions = append(branch.ScriptActions, action)
+			return
+		}
+		currentEvent.ScriptActions = append(currentEvent.ScriptActions, action)
+	}
+	addButton := func(button ScriptButton) {
+		if len(blocks) > 0 {
+			branch := blocks[len(blocks)-1].lastBranch()
+			branch.ScriptButtons = append(branch.ScriptButtons, button)
+			return
+		}
+		currentEvent.ScriptButtons = append(currentEvent.ScriptButtons, button)
+	}
+	closeBlock := func() {
+		block := blocks[len(blocks)-1]
+		blocks = blocks[:len(blocks)-1]
+		addAction(ScriptAction{
+			Variable: block.kind,
+			Operator: "",
+			Value:    block.branches,
+		})
+	}
+	// Closes the blocks that are missing an end at the end of an event
+	closeOpenBlocks := func() {
+		for len(blocks) > 0 {
+			block := blocks[len(blocks)-1]
+			errs = append(errs, &ScriptError{Line: block.line, Column: block.column, Message: block.kind + " block is missing an end"})
+			closeBlock()
+		}
+	}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	for i, rawLine := range lines {
+		line := strings.TrimSpace(rawLine)
 		if line == "" {
 			continue
 		}
@@ -247,112 +621,451 @@ func parseScript(script string) []ScriptEvent {
 			continue
 		}
 
+		// column of the first character after the command character
+		column := strings.Index(rawLine, line) + 2
+		addError := func(err error) {
+			scriptError := &ScriptError{Line: i + 1, Column: column - 1, Message: err.Error()}
+			if se, ok := err.(*syntaxError); ok {
+				scriptError.Column = column + se.column - 1
+			}
+			errs = append(errs, scriptError)
+		}
+
+		if currentEvent == nil && !strings.HasPrefix(line, "===") {
+			addError(fmt.Errorf("line is outside of an event, start an event with '=== Event name'"))
+			continue
+		}
+
+		var block *openBlock
+		if len(blocks) > 0 {
+			block = blocks[len(blocks)-1]
+		}
+
+		if block != nil && strings.ContainsAny(line[:1], "?%*>@") {
+			addError(fmt.Errorf("%c lines can't be used inside %s block", line[0], block.article()))
+			continue
+		}
+
+		if currentEvent != nil && currentEvent.Modifies == "patch" && strings.ContainsAny(line[:1], "%>@") {
+			addError(fmt.Errorf("%c lines can't be used in a patch, use override to replace the event", line[0]))
+			continue
+		}
+
+		// Weighted branch of a random block
+		if block != nil && block.kind == "random" && isWeightLine(line) {
+			column = strings.Index(rawLine, line) + 1
+			weight, action, err := parseWeight(line)
+			if err != nil {
+				addError(err)
+			}
+			block.branches = append(block.branches, ScriptBranch{Weight: weight})
+			if action != nil {
+				addAction(*action)
+			}
+			continue
+		}
+		if block != nil && block.kind == "random" && len(block.branches) == 0 && line != "end" {
+			column = strings.Index(rawLine, line) + 1
+			addError(newSyntaxError(1, "random block must start with a weight, like '50: ! mood += 5'"))
+			continue
+		}
+
 		switch {
 		case strings.HasPrefix(line, "==="): // Event name
 			if currentEvent != nil {
+				closeOpenBlocks()
 				events = append(events, *currentEvent)
 			}
+			modifies, name := parseEventHeader(line[3:])
 			currentEvent = &ScriptEvent{
-				Name:             strings.TrimSpace(line[3:]),
+				Name:             name,
 				ScriptConditions: []ScriptCondition{},
 				ScriptActions:    []ScriptAction{},
 				Choices:          map[string]Choice{},
+				Modifies:         modifies,
+			}
+			if currentEvent.Name == "" {
+				addError(fmt.Errorf("event has no name"))
+			} else if modifies == "" && strings.Contains(currentEvent.Name, "/") {
+				addError(fmt.Errorf("event names can't contain /, it separates the mod name from the event name"))
+			}
+
+		case line == "if" || strings.HasPrefix(line, "if "): // If block
+			column = strings.Index(rawLine, line) + 1 + len("if")
+			condition, err := parseCondition(line[len("if"):])
+			if err != nil {
+				addError(err)
+				condition = ScriptCondition{literalExpr{false}}
+			}
+			blocks = append(blocks, &openBlock{
+				kind:     "if",
+				branches: []ScriptBranch{{Condition: &condition}},
+				line:     i + 1,
+				column:   column - len("if"),
+			})
+
+		case line == "random" || strings.HasPrefix(line, "!") && strings.TrimSpace(line[1:]) == "random": // Random block
+			blocks = append(blocks, &openBlock{
+				kind:   "random",
+				line:   i + 1,
+				column: strings.Index(rawLine, line) + 1,
+			})
+
+		case line == "else if" || strings.HasPrefix(line, "else if "): // Else if branch
+			column = strings.Index(rawLine, line) + 1
+			if block == nil || block.kind != "if" {
+				addError(newSyntaxError(1, "else if without if"))
+				continue
+			}
+			if block.hasElse {
+				addError(newSyntaxError(1, "else if after else"))
+				continue
+			}
+			column += len("else if")
+			condition, err := parseCondition(line[len("else if"):])
+			if err != nil {
+				addError(err)
+				condition = ScriptCondition{literalExpr{false}}
+			}
+			block.branches = append(block.branches, ScriptBranch{Condition: &condition})
+
+		case line == "else": // Else branch
+			column = strings.Index(rawLine, line) + 1
+			if block == nil || block.kind != "if" {
+				addError(newSyntaxError(1, "else without if"))
+				continue
+			}
+			if block.hasElse {
+				addError(newSyntaxError(1, "if block already has an else"))
+				continue
+			}
+			block.hasElse = true
+			block.branches = append(block.branches, ScriptBranch{})
+
+		case line == "end": // End of if or random block
+			column = strings.Index(rawLine, line) + 1
+			if block == nil {
+				addError(newSyntaxError(1, "end without if"))
+				continue
+			}
+			if block.kind == "random" && len(block.branches) == 0 {
+				addError(newSyntaxError(1, "random block has no branches"))
+			}
+			closeBlock()
+
+		case line == "cooldown" || strings.HasPrefix(line, "cooldown "), line == "max" || strings.HasPrefix(line, "max "), line == "priority" || strings.HasPrefix(line, "priority "): // Cooldown, max runs and priority
+			keyword, _, _ := strings.Cut(line, " ")
+			column = strings.Index(rawLine, line) + 1
+			if block != nil {
+				addError(newSyntaxError(1, "%s can't be used inside %s block", keyword, block.article()))
+				continue
+			}
+			column += len(keyword)
+			if keyword == "priority" {
+				priority, err := parsePriority(line[len(keyword):])
+				if err != nil {
+					addError(err)
+					continue
+				}
+				currentEvent.Priority = priority
+				continue
+			}
+			limit, err := parseLimit(keyword, line[len(keyword):])
+			if err != nil {
+				addError(err)
+				continue
+			}
+			if keyword == "cooldown" {
+				currentEvent.Cooldown = limit
+			} else {
+				currentEvent.MaxRuns = limit
 			}
 
 		case strings.HasPrefix(line, "?"): // Condition
-			if currentEvent != nil {
-				condition := parseCondition(line[1:])
-				currentEvent.ScriptConditions = append(currentEvent.ScriptConditions, condition)
+			condition, err := parseCondition(line[1:])
+			if err != nil {
+				addError(err)
+				continue
+			}
+			currentEvent.ScriptConditions = append(currentEvent.ScriptConditions, condition)
+
+		case strings.HasPrefix(line, "@"): // Schedule
+			if currentEvent.Schedule != nil {
+				addError(fmt.Errorf("event already has a schedule"))
+				continue
+			}
+			schedule, err := parseSchedule(line[1:])
+			if err != nil {
+				addError(err)
+				continue
 			}
+			currentEvent.Schedule = &schedule
 
 		case strings.HasPrefix(line, "%"): // ProgressMax
-			progressMax := parseProgressMax(line[1:])
+			progressMax, err := parseProgressMax(line[1:])
+			if err != nil {
+				addError(err)
+				continue
+			}
 			currentEvent.ProgressMax = progressMax
 
 		case strings.HasPrefix(line, "!"): // Action
-			if currentEvent != nil {
-				action := parseAction(line[1:])
-				currentEvent.ScriptActions = append(currentEvent.ScriptActions, action)
+			action, err := parseAction(line[1:])
+			if err != nil {
+				addError(err)
+				continue
 			}
+			addAction(action)
 
 		case strings.HasPrefix(line, "*"): // Choice
-			if currentEvent != nil {
-				key, value, conditions, err := parseChoice(line[1:])
-				if err != nil {
-					log.Fatal(err)
-				}
-				currentEvent.Choices[key] = Choice{
-					ButtonText: key,
-					EventName:  value,
-					Conditions: conditions,
-				}
+			key, value, conditions, err := parseChoice(line[1:])
+			if err != nil {
+				addError(err)
+				continue
+			}
+			order := len(currentEvent.Choices)
+			if existing, ok := currentEvent.Choices[key]; ok {
+				order = existing.Order
+			}
+			currentEvent.Choices[key] = Choice{
+				ButtonText: key,
+				EventName:  value,
+				Conditions: conditions,
+				Order:      order,
 			}
 
 		case strings.HasPrefix(line, "+"): // Button Addition
-			if currentEvent != nil {
-				button := parseButton(line[1:])
-				currentEvent.ScriptButtons = append(currentEvent.ScriptButtons, button)
+			button, err := parseButton(line[1:])
+			if err == nil && button.EventName == "" {
+				err = newSyntaxError(1, "invalid button syntax, expected '+ button text -> event name'")
+			}
+			if err != nil {
+				addError(err)
+				continue
 			}
+			addButton(button)
 
 		case strings.HasPrefix(line, "-"): // Button Removal
-			if currentEvent != nil {
-				button := parseButton(line[1:])
-				currentEvent.ScriptButtons = append(currentEvent.ScriptButtons, button)
+			button, err := parseButton(line[1:])
+			if err == nil && button.EventName != "" {
+				err = newSyntaxError(strings.Index(line, "->"), "a button removal only needs the button text")
+			}
+			if err != nil {
+				addError(err)
+				continue
 			}
+			addButton(button)
 
 		case strings.HasPrefix(line, ">"):
-			if currentEvent != nil {
-				retStr := strings.TrimSpace(line[1:])
-				switch retStr {
-				case "true":
-					currentEvent.Return = true
-				case "false":
-					currentEvent.Return = false
-				default:
-					log.Fatal("Error parsing ScriptEvent Return:", retStr)
-				}
+			retStr := strings.TrimSpace(line[1:])
+			switch retStr {
+			case "true":
+				currentEvent.Return = true
+			case "false":
+				currentEvent.Return = false
+			default:
+				addError(newSyntaxError(strings.Index(line[1:], retStr)+1, "return value must be true or false: %s", retStr))
 			}
+
+		default:
+			column = strings.Index(rawLine, line) + 1
+			addError(newSyntaxError(1, "unknown command: %s", line))
 		}
 	}
 
 	// Append the final event
 	if currentEvent != nil {
+		closeOpenBlocks()
 		events = append(events, *currentEvent)
 	}
 
-	return events
+	if len(errs) > 0 {
+		return events, errs
+	}
+	return events, nil
+}
+
+// Splits the text after === into "override" or "patch" and the name of the
+// event, modifies is empty for new events
+func parseEventHeader(s string) (modifies, name string) {
+	name = strings.TrimSpace(s)
+	for _, keyword := range []string{"override", "patch"} {
+		if target, ok := strings.CutPrefix(name, keyword+" "); ok {
+			return keyword, strings.TrimSpace(target)
+		}
+	}
+	return "", name
+}
+
+// An if or random block that is still being parsed
+type openBlock struct {
+	// "if" or "random"
+	kind     string
+	branches []ScriptBranch
+	// position of the if or random, for the error if the end is missing
+	line   int
+	column int
+	// true once the else branch was added
+	hasElse bool
+}
+
+// Returns the branch that actions are currently added to
+func (b *openBlock) lastBranch() *ScriptBranch {
+	return &b.branches[len(b.branches)-1]
+}
+
+// Returns the kind of the block with an article, for error messages
+func (b *openBlock) article() string {
+	if b.kind == "if" {
+		return "an if"
+	}
+	return "a " + b.kind
+}
+
+// Reports whether line starts a branch of a random block, like "60:"
+func isWeightLine(line string) bool {
+	colon := strings.Index(line, ":")
+	return colon > 0 && strings.Trim(line[:colon], "0123456789 ") == ""
+}
+
+// Parses the start of a branch of a random block in the format:
+// weight: [!] action
+// The action after the colon is optional.
+func parseWeight(line string) (int, *ScriptAction, error) {
+	colon := strings.Index(line, ":")
+	weight, err := strconv.Atoi(strings.TrimSpace(line[:colon]))
+	if err != nil || weight <= 0 {
+		return 0, nil, newSyntaxError(1, "weight must be a positive whole number: %s", strings.TrimSpace(line[:colon]))
+	}
+
+	rest := strings.TrimSpace(line[colon+1:])
+	if rest == "" {
+		return weight, nil, nil
+	}
+	offset := strings.Index(line[colon+1:], rest) + colon + 1
+	if strings.HasPrefix(rest, "!") {
+		rest = rest[1:]
+		offset++
+	}
+	action, err := parseAction(rest)
+	if err != nil {
+		return weight, nil, shiftError(err, offset)
+	}
+	return weight, &action, nil
 }
 
 // Parses a line representing a button addition or removal into a ScriptButton
-func parseButton(s string) ScriptButton {
+func parseButton(s string) (ScriptButton, error) {
 	// if the line is in the format: button name -> event name
 	// it is a button addition
 	if strings.Contains(s, "->") {
 		parts := strings.Split(s, "->")
 		if len(parts) != 2 {
-			log.Fatal("Error parsing ScriptButton:", s)
+			return ScriptButton{}, newSyntaxError(len(parts[0])+len(parts[1])+3, "invalid button syntax, only one -> is allowed")
 		}
-		return ScriptButton{
+		button := ScriptButton{
 			strings.TrimSpace(parts[0]),
 			strings.TrimSpace(parts[1]),
 		}
+		if button.ButtonText == "" {
+			return ScriptButton{}, newSyntaxError(1, "button text is missing")
+		}
+		if button.EventName == "" {
+			return ScriptButton{}, newSyntaxError(len(s), "event name is missing after ->")
+		}
+		return button, nil
 	}
 
 	// if the line is in the format: button name
 	// it is a button removal
-	return ScriptButton{
+	button := ScriptButton{
 		strings.TrimSpace(s),
 		"",
 	}
+	if button.ButtonText == "" {
+		return ScriptButton{}, newSyntaxError(1, "button text is missing")
+	}
+	return button, nil
 }
 
-func parseProgressMax(s string) int {
-	max, err := strconv.Atoi(strings.TrimSpace(s))
+func parseProgressMax(s string) (int, error) {
+	fs := fields(s)
+	if len(fs) != 1 {
+		return 0, newSyntaxError(1, "progress max must be a single number: %s", strings.TrimSpace(s))
+	}
+	max, err := strconv.Atoi(fs[0].text)
+	if err != nil || max <= 0 {
+		return 0, newSyntaxError(fs[0].column, "progress max must be a number greater than 0: %s", fs[0].text)
+	}
+	return max, nil
+}
+
+// Parses the number of a cooldown or max line, keyword is the name of the
+// line for error messages
+func parseLimit(keyword string, s string) (int, error) {
+	fs := fields(s)
+	if len(fs) == 0 {
+		return 0, newSyntaxError(1, "%s needs a number", keyword)
+	}
+	if len(fs) != 1 {
+		return 0, newSyntaxError(1, "%s must be a single number: %s", keyword, strings.TrimSpace(s))
+	}
+	limit, err := strconv.Atoi(fs[0].text)
+	if err != nil || limit <= 0 {
+		return 0, newSyntaxError(fs[0].column, "%s must be a number greater than 0: %s", keyword, fs[0].text)
+	}
+	return limit, nil
+}
+
+// Parses the number of a priority line, it can be negative to check an
+// event after the others
+func parsePriority(s string) (int, error) {
+	fs := fields(s)
+	if len(fs) != 1 {
+		return 0, newSyntaxError(1, "priority must be a single number: %s", strings.TrimSpace(s))
+	}
+	priority, err := strconv.Atoi(fs[0].text)
 	if err != nil {
-		log.Printf("Error parsing ScriptEvent ProgressMax: %s\n", s)
-		return 0
+		return 0, newSyntaxError(fs[0].column, "priority must be a whole number: %s", fs[0].text)
+	}
+	return priority, nil
+}
+
+// parseSchedule parses a schedule line in one of the formats:
+// "every 600", "at 1200" or "after 100 ticks since event name"
+func parseSchedule(s string) (ScriptSchedule, error) {
+	parts := fields(s)
+	if len(parts) < 2 {
+		return ScriptSchedule{}, newSyntaxError(1, "invalid schedule, expected 'every <ticks>', 'at <tick>' or 'after <ticks> ticks since <event name>'")
+	}
+	ticks, err := strconv.Atoi(parts[1].text)
+	if err != nil || ticks <= 0 {
+		return ScriptSchedule{}, newSyntaxError(parts[1].column, "ticks must be a number greater than 0: %s", parts[1].text)
+	}
+
+	switch parts[0].text {
+	case "every", "at":
+		if len(parts) > 2 {
+			return ScriptSchedule{}, newSyntaxError(parts[2].column, "unexpected %s", parts[2].text)
+		}
+		return ScriptSchedule{parts[0].text, ticks, ""}, nil
+
+	case "after":
+		rest := parts[2:]
+		if len(rest) > 0 && rest[0].text == "ticks" {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 || rest[0].text != "since" {
+			return ScriptSchedule{}, newSyntaxError(parts[1].column, "expected 'after <ticks> ticks since <event name>'")
+		}
+		if len(rest) == 1 {
+			return ScriptSchedule{}, newSyntaxError(rest[0].column, "event name is missing after since")
+		}
+		return ScriptSchedule{"after", ticks, joinFields(rest[1:])}, nil
+
+	default:
+		return ScriptSchedule{}, newSyntaxError(parts[0].column, "unknown schedule %s, expected every, at or after", parts[0].text)
 	}
-	return max
 }
 
 // parseChoice parses a choice line in the format:
@@ -361,23 +1074,37 @@ func parseProgressMax(s string) int {
 func parseChoice(s string) (string, string, []ScriptCondition, error) {
 	parts := strings.SplitN(s, ":", 2) // Split at most once
 	var conditions []ScriptCondition
+	offset := 0
 
 	if len(parts) == 2 {
 		conditionStrings := strings.Split(parts[0], ",")
 		for _, conditionString := range conditionStrings {
-			condition := parseCondition(strings.TrimSpace(conditionString))
+			condition, err := parseCondition(conditionString)
+			if err != nil {
+				return "", "", nil, shiftError(err, offset)
+			}
 			conditions = append(conditions, condition)
+			offset += len(conditionString) + 1
 		}
 		s = parts[1] // Keep only the right-hand side for further parsing
 	}
 
+	// column of the first character of the button text
+	column := offset + len(s) - len(strings.TrimLeft(s, " \t")) + 1
+
 	choiceParts := strings.SplitN(s, "->", 2) // Split at most once
 	if len(choiceParts) != 2 {
-		return "", "", nil, fmt.Errorf("invalid choice syntax: %s", s)
+		return "", "", nil, newSyntaxError(column, "invalid choice syntax, expected 'button text -> event name': %s", strings.TrimSpace(s))
 	}
 
 	key := strings.TrimSpace(choiceParts[0])
 	value := strings.TrimSpace(choiceParts[1])
+	if key == "" {
+		return "", "", nil, newSyntaxError(column, "button text is missing")
+	}
+	if value == "" {
+		return "", "", nil, newSyntaxError(offset+len(s), "event name is missing after ->")
+	}
 
 	return key, value, conditions, nil
 }
@@ -385,65 +1112,78 @@ func parseChoice(s string) (string, string, []ScriptCondition, error) {
 // parseCondition parses a condition line.
 // Examples:
 //
-//	"mood <= 10"  -> variable: mood, operator: <=, value: 10 (int)
-//	"status == happy" -> variable: status, operator: ==, value: "happy"
-func parseCondition(line string) ScriptCondition {
-	parts := strings.Fields(strings.TrimSpace(line))
-	// Handle literal booleans
-	if len(parts) == 1 {
-		var boolean bool
-		switch parts[0] {
-		case "true":
-			boolean = true
-		case "false":
-			boolean = false
-		default:
-			panic(fmt.Sprintf("Invalid boolean value: %s", parts[0]))
+//	"mood <= 10"  -> mood <= 10 (int)
+//	"money > salary * 3" -> money > (salary * 3)
+//	"status == happy" -> status == happy
+//	"(mood < 10 or energy < 5) and not working"
+func parseCondition(line string) (ScriptCondition, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return ScriptCondition{}, err
+	}
+	if len(tokens) == 0 {
+		return ScriptCondition{}, newSyntaxError(1, "condition is missing")
+	}
+
+	// Catch operators that look like comparisons but aren't
+	for i, t := range tokens {
+		if t.kind != tokenOperator {
+			continue
 		}
-		return ScriptCondition{
-			Variable: "boolean",
-			Operator: "",
-			Value:    boolean,
+		if slices.Contains(actionOperators, t.text) || slices.Contains(conditionOperators, t.text) && isGluedOperator(tokens, i) {
+			return ScriptCondition{}, unknownOperatorError("condition", tokens, i, conditionOperators)
 		}
 	}
 
-	if len(parts) < 3 {
-		panic(fmt.Sprintf("Invalid condition syntax: %s", line))
+	expr, err := parseConditionTokens(tokens, len(line)+1)
+	if err != nil {
+		return ScriptCondition{}, err
 	}
+	return ScriptCondition{expr}, nil
+}
 
-	// Attempt to parse the third part as an int
-	if val, err := strconv.Atoi(parts[2]); err == nil {
-		return ScriptCondition{
-			Variable: parts[0],
-			Operator: parts[1],
-			Value:    val,
-		}
+// Parses the fields of a trigger action in the format
+// "trigger event name" or "trigger event name in 50"
+func parseTrigger(parts []field) (ScriptAction, error) {
+	if len(parts) < 2 {
+		return ScriptAction{}, newSyntaxError(parts[0].column, "trigger needs an event name")
 	}
 
-	// Attempt to parse the third part as a float
-	if val, err := strconv.ParseFloat(parts[2], 64); err == nil {
-		return ScriptCondition{
-			Variable: parts[0],
-			Operator: parts[1],
-			Value:    val,
+	trigger := ScriptTrigger{EventName: joinFields(parts[1:])}
+	if n := len(parts); n >= 4 && parts[n-2].text == "in" {
+		if delay, err := strconv.Atoi(parts[n-1].text); err == nil {
+			if delay < 0 {
+				return ScriptAction{}, newSyntaxError(parts[n-1].column, "trigger delay can't be negative: %d", delay)
+			}
+			trigger = ScriptTrigger{joinFields(parts[1 : n-2]), delay}
 		}
 	}
 
-	// Attempt to parse the third part as a boolean
-	if val, err := strconv.ParseBool(parts[2]); err == nil {
-		return ScriptCondition{
-			Variable: parts[0],
-			Operator: parts[1],
-			Value:    val,
-		}
+	return ScriptAction{
+		Variable: "trigger",
+		Operator: "",
+		Value:    trigger,
+	}, nil
+}
+
+// Reports whether the operator at tokens[i] is directly followed by another
+// operator like in ">>" or "++", which is most likely a typo
+func isGluedOperator(tokens []token, i int) bool {
+	if i+1 >= len(tokens) || tokens[i+1].kind != tokenOperator {
+		return false
 	}
+	next := tokens[i+1]
+	return next.column == tokens[i].column+len(tokens[i].text) && next.text != "-" && next.text != "("
+}
 
-	// If not an int, bool or float, keep it as a string
-	return ScriptCondition{
-		Variable: parts[0],
-		Operator: parts[1],
-		Value:    strings.Join(parts[2:], " "),
+// Returns an error for an operator that can't be used in a condition or
+// action, kind is either "condition" or "action"
+func unknownOperatorError(kind string, tokens []token, i int, expected []string) error {
+	operator := tokens[i].text
+	if isGluedOperator(tokens, i) {
+		operator += tokens[i+1].text
 	}
+	return newSyntaxError(tokens[i].column, "unknown %s operator %s, expected one of %s", kind, operator, strings.Join(expected, " "))
 }
 
 // parseAction parses an action line.
@@ -451,63 +1191,51 @@ func parseCondition(line string) ScriptCondition {
 //
 //	"print You went outside" -> variable: print, value: "You went outside"
 //	"mood += 10" -> variable: mood, operator: +=, value: 10 (int)
-func parseAction(line string) ScriptAction {
-	parts := strings.Fields(strings.TrimSpace(line))
-	if len(parts) < 2 {
-		panic(fmt.Sprintf("Invalid action syntax: %s", line))
+//	"food += foodMax / 10" -> variable: food, operator: +=, value: foodMax / 10
+//	"trigger Go home in 50" -> variable: trigger, value: Go home after 50 ticks
+func parseAction(line string) (ScriptAction, error) {
+	parts := fields(line)
+	if len(parts) == 0 {
+		return ScriptAction{}, newSyntaxError(1, "action is missing")
 	}
 
-	// Special handling for print commands
-	if parts[0] == "print" {
-		return ScriptAction{
-			Variable: "print",
-			Operator: "",
-			Value:    strings.Join(parts[1:], " "),
+	// Special handling for print and show commands
+	if parts[0].text == "print" || parts[0].text == "show" {
+		if len(parts) < 2 {
+			return ScriptAction{}, newSyntaxError(parts[0].column, "%s needs a value", parts[0].text)
 		}
-	}
-
-	// Special handling for show commands
-	if parts[0] == "show" {
 		return ScriptAction{
-			Variable: "show",
+			Variable: parts[0].text,
 			Operator: "",
-			Value:    strings.Join(parts[1:], " "),
-		}
+			Value:    joinFields(parts[1:]),
+		}, nil
 	}
 
-	// For other actions, try parsing the value as an int, float64, bool, or string
-	if len(parts) >= 3 {
-		if val, err := strconv.Atoi(parts[2]); err == nil {
-			return ScriptAction{
-				Variable: parts[0],
-				Operator: parts[1],
-				Value:    val,
-			}
-		}
-
-		if val, err := strconv.ParseBool(parts[2]); err == nil {
-			return ScriptAction{
-				Variable: parts[0],
-				Operator: parts[1],
-				Value:    val,
-			}
-		}
-
-		if val, err := strconv.ParseFloat(parts[2], 64); err == nil {
-			return ScriptAction{
-				Variable: parts[0],
-				Operator: parts[1],
-				Value:    val,
-			}
-		}
+	if parts[0].text == "trigger" {
+		return parseTrigger(parts)
+	}
 
-		// Otherwise, treat it as a string
-		return ScriptAction{
-			Variable: parts[0],
-			Operator: parts[1],
-			Value:    strings.Join(parts[2:], " "),
-		}
+	tokens, err := tokenize(line)
+	if err != nil {
+		return ScriptAction{}, err
+	}
+	if len(tokens) < 3 {
+		return ScriptAction{}, newSyntaxError(parts[0].column, "invalid action syntax, expected 'variable operator value': %s", strings.TrimSpace(line))
+	}
+	if tokens[0].kind != tokenWord {
+		return ScriptAction{}, newSyntaxError(tokens[0].column, "expected a variable name: %s", tokens[0].text)
+	}
+	if tokens[1].kind != tokenOperator || !slices.Contains(actionOperators, tokens[1].text) || isGluedOperator(tokens, 1) {
+		return ScriptAction{}, unknownOperatorError("action", tokens, 1, actionOperators)
 	}
 
-	panic(fmt.Sprintf("Invalid action syntax: %s", line))
+	value, err := parseExpressionTokens(tokens[2:], len(line)+1)
+	if err != nil {
+		return ScriptAction{}, err
+	}
+	return ScriptAction{
+		Variable: tokens[0].text,
+		Operator: tokens[1].text,
+		Value:    value,
+	}, nil
 }
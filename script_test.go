@@ -20,16 +20,30 @@ func TestParseCondition(t *testing.T) {
 		input    string
 		expected ScriptCondition
 	}{
-		{"mood <= 10", ScriptCondition{"mood", "<=", 10}},
-		{"energy > 20", ScriptCondition{"energy", ">", 20}},
-		{"status == happy", ScriptCondition{"status", "==", "happy"}},
-		{"isRaining == true", ScriptCondition{"isRaining", "==", true}},
-		{"routineShave == true", ScriptCondition{"routineShave", "==", true}},
-		{"eventName == Morning Routine", ScriptCondition{"eventName", "==", "Morning Routine"}},
+		{"mood <= 10", ScriptCondition{compareExpr{"<=", variableExpr{"mood"}, literalExpr{10}}}},
+		{"energy > 20", ScriptCondition{compareExpr{">", variableExpr{"energy"}, literalExpr{20}}}},
+		{"status == happy", ScriptCondition{compareExpr{"==", variableExpr{"status"}, variableExpr{"happy"}}}},
+		{"isRaining == true", ScriptCondition{compareExpr{"==", variableExpr{"isRaining"}, literalExpr{true}}}},
+		{"routineShave == true", ScriptCondition{compareExpr{"==", variableExpr{"routineShave"}, literalExpr{true}}}},
+		{"eventName == Morning Routine", ScriptCondition{compareExpr{"==", variableExpr{"eventName"}, literalExpr{"Morning Routine"}}}},
+		{"true", ScriptCondition{literalExpr{true}}},
+		{"(mood < 10 or energy < 5) and not working", ScriptCondition{logicExpr{"and",
+			logicExpr{"or", compareExpr{"<", variableExpr{"mood"}, literalExpr{10}}, compareExpr{"<", variableExpr{"energy"}, literalExpr{5}}},
+			notExpr{variableExpr{"working"}},
+		}}},
+		{"eventName == Morning Routine or eventName == Sleeping", ScriptCondition{logicExpr{"or",
+			compareExpr{"==", variableExpr{"eventName"}, literalExpr{"Morning Routine"}},
+			compareExpr{"==", variableExpr{"eventName"}, variableExpr{"Sleeping"}},
+		}}},
+		{"money > salary * 3", ScriptCondition{compareExpr{">", variableExpr{"money"}, binaryExpr{"*", variableExpr{"salary"}, literalExpr{3}}}}},
+		{"rand < 0.001", ScriptCondition{compareExpr{"<", variableExpr{"rand"}, literalExpr{0.001}}}},
 	}
 
 	for _, test := range tests {
-		result := parseCondition(test.input)
+		result, err := parseCondition(test.input)
+		if err != nil {
+			t.Errorf("parseCondition(%q) returned error: %s", test.input, err)
+		}
 		if result != test.expected {
 			t.Errorf("parseCondition(%q) = %+v, expected %+v", test.input, result, test.expected)
 		}
@@ -42,12 +56,19 @@ func TestParseAction(t *testing.T) {
 		expected ScriptAction
 	}{
 		{"print You went outside", ScriptAction{"print", "", "You went outside"}},
-		{"fitness += 10", ScriptAction{"fitness", "+=", 10}},
-		{"energy -= 5", ScriptAction{"energy", "-=", 5}},
+		{"fitness += 10", ScriptAction{"fitness", "+=", literalExpr{10}}},
+		{"energy -= 5", ScriptAction{"energy", "-=", literalExpr{5}}},
+		{"job = Sales clerk", ScriptAction{"job", "=", literalExpr{"Sales clerk"}}},
+		{"job = Part-time clerk", ScriptAction{"job", "=", literalExpr{"Part-time clerk"}}},
+		{"mood += -5", ScriptAction{"mood", "+=", literalExpr{-5}}},
+		{"food += foodMax / 10", ScriptAction{"food", "+=", binaryExpr{"/", variableExpr{"foodMax"}, literalExpr{10}}}},
 	}
 
 	for _, test := range tests {
-		result := parseAction(test.input)
+		result, err := parseAction(test.input)
+		if err != nil {
+			t.Errorf("parseAction(%q) returned error: %s", test.input, err)
+		}
 		if result != test.expected {
 			t.Errorf("parseAction(%q) = %+v, expected %+v", test.input, result, test.expected)
 		}
@@ -62,7 +83,10 @@ func TestParseScript(t *testing.T) {
 ! fitness += 10
 > true`
 
-	scriptEvents := parseScript(script)
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
 
 	// Check event count
 	if len(scriptEvents) != 1 {
@@ -93,13 +117,13 @@ func TestParseScript(t *testing.T) {
 
 	// Check first condition
 	cond1 := scriptEvent.ScriptConditions[0]
-	if cond1.Variable != "mood" || cond1.Operator != "<=" || cond1.Value != 10 {
+	if cond1.Expr != (compareExpr{"<=", variableExpr{"mood"}, literalExpr{10}}) {
 		t.Errorf("First script condition mismatch: got %+v", cond1)
 	}
 
 	// Check second condition
 	cond2 := scriptEvent.ScriptConditions[1]
-	if cond2.Variable != "energy" || cond2.Operator != ">" || cond2.Value != 20 {
+	if cond2.Expr != (compareExpr{">", variableExpr{"energy"}, literalExpr{20}}) {
 		t.Errorf("Second script condition mismatch: got %+v", cond2)
 	}
 
@@ -111,11 +135,347 @@ func TestParseScript(t *testing.T) {
 
 	// Check second action (fitness += 10)
 	act2 := scriptEvent.ScriptActions[1]
-	if act2.Variable != "fitness" || act2.Operator != "+=" || act2.Value != 10 {
+	if act2.Variable != "fitness" || act2.Operator != "+=" || act2.Value != (literalExpr{10}) {
 		t.Errorf("Second script action mismatch: got %+v", act2)
 	}
 }
 
+func TestParseScriptErrors(t *testing.T) {
+	script := `! print outside
+=== Broken
+? mood >> 10
+! print
+  * Go nowhere
+> maybe
+=== Fine
+~ unknown`
+
+	scriptEvents, err := parseScript(script)
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("Expected ScriptErrors, got %v", err)
+	}
+
+	// Lines with errors are skipped, the events are still parsed
+	if len(scriptEvents) != 2 {
+		t.Errorf("Expected 2 script events, got %d", len(scriptEvents))
+	}
+
+	expected := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{1, 1, "line is outside of an event, start an event with '=== Event name'"},
+		{3, 8, "unknown condition operator >>, expected one of == != < > <= >="},
+		{4, 3, "print needs a value"},
+		{5, 5, "invalid choice syntax, expected 'button text -> event name': Go nowhere"},
+		{6, 3, "return value must be true or false: maybe"},
+		{8, 1, "unknown command: ~ unknown"},
+	}
+	if len(scriptErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(scriptErrors), scriptErrors)
+	}
+	for i, e := range expected {
+		scriptError := scriptErrors[i]
+		if scriptError.Line != e.line || scriptError.Column != e.column || scriptError.Message != e.message {
+			t.Errorf("Expected %d:%d: %s, got %s", e.line, e.column, e.message, scriptError)
+		}
+	}
+}
+
+func TestParseChoiceConditionErrorColumn(t *testing.T) {
+	_, _, _, err := parseChoice(" mood > 1, energy ~ 2: Go -> Walk")
+	se, ok := err.(*syntaxError)
+	if !ok {
+		t.Fatalf("Expected a syntax error, got %v", err)
+	}
+	if se.column != 19 {
+		t.Errorf("Expected error in column 19, got %d", se.column)
+	}
+}
+
+func TestParseIfBlocks(t *testing.T) {
+	script := `=== Shopping
+? true
+if money > 500
+  ! print You bought a TV.
+  if mood < 50
+    ! mood += 10
+  end
+else if money > 100
+  ! print You bought a radio.
+  + Listen -> Listening
+else
+  ! print You can't afford anything.
+end
+! print Done shopping.`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	actions := scriptEvents[0].ScriptActions
+	if len(actions) != 2 || actions[0].Variable != "if" || actions[1].Variable != "print" {
+		t.Fatalf("Expected an if block followed by a print, got %+v", actions)
+	}
+	branches := actions[0].Value.([]ScriptBranch)
+	if len(branches) != 3 {
+		t.Fatalf("Expected 3 branches, got %d", len(branches))
+	}
+	if branches[0].Condition == nil || branches[1].Condition == nil || branches[2].Condition != nil {
+		t.Errorf("Expected conditions for if and else if, but not for else")
+	}
+	if len(branches[0].ScriptActions) != 2 || branches[0].ScriptActions[1].Variable != "if" {
+		t.Errorf("Expected a nested if block in the first branch, got %+v", branches[0].ScriptActions)
+	}
+	if len(branches[1].ScriptButtons) != 1 || branches[1].ScriptButtons[0].EventName != "Listening" {
+		t.Errorf("Expected a button in the else if branch, got %+v", branches[1].ScriptButtons)
+	}
+}
+
+func TestParseIfBlockErrors(t *testing.T) {
+	script := `=== Broken
+else
+if money >
+  ? mood > 5
+end
+end
+=== Unclosed
+if true
+! print Never closed`
+
+	_, err := parseScript(script)
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("Expected ScriptErrors, got %v", err)
+	}
+
+	expected := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{2, 1, "else without if"},
+		{3, 11, "expression is incomplete"},
+		{4, 3, "? lines can't be used inside an if block"},
+		{6, 1, "end without if"},
+		{8, 1, "if block is missing an end"},
+	}
+	if len(scriptErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(scriptErrors), scriptErrors)
+	}
+	for i, e := range expected {
+		scriptError := scriptErrors[i]
+		if scriptError.Line != e.line || scriptError.Column != e.column || scriptError.Message != e.message {
+			t.Errorf("Expected %d:%d: %s, got %s", e.line, e.column, e.message, scriptError)
+		}
+	}
+}
+
+func TestIfBlockActions(t *testing.T) {
+	script := `=== Shopping
+if money > 500
+  ! money -= 500
+  ! mood += 10
+else if money > 100
+  ! money -= 100
+  + Listen -> Listening
+else
+  ! mood -= 5
+end
+! fitness += 1`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	tests := []struct {
+		money, expectedMoney, expectedMood int
+		expectedButton                     bool
+	}{
+		{600, 100, 60, false},
+		{200, 100, 50, true},
+		{50, 50, 45, false},
+	}
+	for _, test := range tests {
+		state := NewAppStateWithDefaults()
+		state.Money.Set(test.money)
+		state.Mood.Set(50)
+		state.Fitness.Set(0)
+
+		event := scriptEventToEvent(state, scriptEvents[0])
+		event.Action()
+
+		if money := state.Get("money"); money != test.expectedMoney {
+			t.Errorf("money %d: expected money to be %d, got %v", test.money, test.expectedMoney, money)
+		}
+		if mood := state.Get("mood"); mood != test.expectedMood {
+			t.Errorf("money %d: expected mood to be %d, got %v", test.money, test.expectedMood, mood)
+		}
+		if fitness := state.Get("fitness"); fitness != 1 {
+			t.Errorf("money %d: expected fitness to be 1, got %v", test.money, fitness)
+		}
+		_, err := state.Buttons.GetValue("Listen")
+		if hasButton := err == nil; hasButton != test.expectedButton {
+			t.Errorf("money %d: expected button %v, got %v", test.money, test.expectedButton, hasButton)
+		}
+	}
+}
+
+func TestParseRandomBlocks(t *testing.T) {
+	script := `=== Weather
+random
+60: mood += 5
+30:
+  ! mood -= 5
+  + Umbrella -> Use umbrella
+10: ! trigger Storm
+end`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	actions := scriptEvents[0].ScriptActions
+	if len(actions) != 1 || actions[0].Variable != "random" {
+		t.Fatalf("Expected a single random action, got %v", actions)
+	}
+	branches := actions[0].Value.([]ScriptBranch)
+	if len(branches) != 3 {
+		t.Fatalf("Expected 3 branches, got %d", len(branches))
+	}
+	for i, weight := range []int{60, 30, 10} {
+		if branches[i].Weight != weight {
+			t.Errorf("Expected branch %d to have weight %d, got %d", i, weight, branches[i].Weight)
+		}
+		if len(branches[i].ScriptActions) != 1 {
+			t.Errorf("Expected branch %d to have 1 action, got %d", i, len(branches[i].ScriptActions))
+		}
+	}
+	if len(branches[1].ScriptButtons) != 1 {
+		t.Errorf("Expected the second branch to have a button, got %v", branches[1].ScriptButtons)
+	}
+	if trigger := branches[2].ScriptActions[0].Value; trigger != (ScriptTrigger{"Storm", 0}) {
+		t.Errorf("Expected the third branch to trigger Storm, got %v", trigger)
+	}
+}
+
+func TestParseRandomBlockErrors(t *testing.T) {
+	script := `=== Broken
+! random
+! mood += 1
+0: mood += 1
+50: mood +=
+else
+end
+random
+end
+random
+  ? mood > 5`
+
+	_, err := parseScript(script)
+	scriptErrors, ok := err.(ScriptErrors)
+	if !ok {
+		t.Fatalf("Expected ScriptErrors, got %v", err)
+	}
+
+	expected := []struct {
+		line    int
+		column  int
+		message string
+	}{
+		{3, 1, "random block must start with a weight, like '50: ! mood += 5'"},
+		{4, 1, "weight must be a positive whole number: 0"},
+		{5, 5, "invalid action syntax, expected 'variable operator value': mood +="},
+		{6, 1, "else without if"},
+		{9, 1, "random block has no branches"},
+		{11, 3, "? lines can't be used inside a random block"},
+		{10, 1, "random block is missing an end"},
+	}
+	if len(scriptErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(scriptErrors), scriptErrors)
+	}
+	for i, e := range expected {
+		scriptError := scriptErrors[i]
+		if scriptError.Line != e.line || scriptError.Column != e.column || scriptError.Message != e.message {
+			t.Errorf("Expected %d:%d: %s, got %s", e.line, e.column, e.message, scriptError)
+		}
+	}
+}
+
+func TestRandomBlockActions(t *testing.T) {
+	script := `=== Coin
+random
+75: heads += 1
+25: tails += 1
+end`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	state := NewAppStateWithDefaults()
+	state.Set("heads", 0)
+	state.Set("tails", 0)
+	event := scriptEventToEvent(state, scriptEvents[0])
+	for range 2000 {
+		event.Action()
+	}
+
+	heads, tails := state.Get("heads").(int), state.Get("tails").(int)
+	if heads+tails != 2000 {
+		t.Fatalf("Expected exactly one branch to run each time, got %d heads and %d tails", heads, tails)
+	}
+	// 75% of 2000 is 1500, this range fails far less than once in a million runs
+	if heads < 1350 || heads > 1650 {
+		t.Errorf("Expected about 1500 heads, got %d", heads)
+	}
+}
+
+func TestSharedRoll(t *testing.T) {
+	script := `=== Lucky day
+? roll < 0.5
+if roll < 0.25
+  ! luck = roll
+else
+  ! luck = 1.0
+end`
+
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
+
+	state := NewAppStateWithDefaults()
+	event := scriptEventToEvent(state, scriptEvents[0])
+	ran, rolls := 0, map[float64]bool{}
+	for range 200 {
+		state.Set("luck", -1.0)
+		state.handleEvent(&event, false)
+
+		luck := NewGameVariable("", state.Get("luck")).Float64()
+		if luck == -1 {
+			continue
+		}
+		ran++
+		if luck != 1 && luck >= 0.25 {
+			t.Errorf("Expected the if block to see the roll of the condition, got %v", luck)
+		}
+		rolls[luck] = true
+	}
+	if ran == 0 || ran == 200 {
+		t.Errorf("Expected the roll to change between runs, the event ran %d of 200 times", ran)
+	}
+	if len(rolls) < 3 {
+		t.Errorf("Expected different rolls, got %v", rolls)
+	}
+}
+
 // -----------------------------
 // Tests for script structs to events
 // -----------------------------
@@ -126,7 +486,10 @@ func TestScriptEventToEvent(t *testing.T) {
 ! print You worked a lot.
 > true`
 
-	scriptEvents := parseScript(script)
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("Error parsing script: %s", err)
+	}
 
 	scriptEvent := scriptEvents[0]
 
@@ -156,7 +519,7 @@ func TestScriptActionToFn(t *testing.T) {
 	state := NewAppStateWithDefaults()
 	state.WorkXP.Set(100)
 
-	action := scriptActionToFn(state, scriptAction, false)
+	action := scriptActionToFn(state, state, scriptAction, false)
 	action()
 
 	if state.Get("workxp") != 200 {
@@ -165,11 +528,12 @@ func TestScriptActionToFn(t *testing.T) {
 }
 
 func TestScriptConditionToFn(t *testing.T) {
-	scriptCondition := ScriptCondition{
-		Variable: "workxp",
-		Operator: "==",
-		Value:    100,
+	comparison := compareExpr{
+		operator: "==",
+		left:     variableExpr{"workxp"},
+		right:    literalExpr{100},
 	}
+	scriptCondition := ScriptCondition{comparison}
 
 	state := NewAppStateWithDefaults()
 	state.WorkXP.Set(100)
@@ -180,7 +544,8 @@ func TestScriptConditionToFn(t *testing.T) {
 		t.Errorf("Expected condition to be true")
 	}
 
-	scriptCondition.Value = 200
+	comparison.right = literalExpr{200}
+	scriptCondition = ScriptCondition{comparison}
 
 	condition2 := scriptConditionToFn(state, scriptCondition)
 
@@ -188,7 +553,8 @@ func TestScriptConditionToFn(t *testing.T) {
 		t.Errorf("Expected condition2 to be false")
 	}
 
-	scriptCondition.Operator = "<"
+	comparison.operator = "<"
+	scriptCondition = ScriptCondition{comparison}
 
 	condition3 := scriptConditionToFn(state, scriptCondition)
 
@@ -0,0 +1,192 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Picks one of the available choices of a choice event
+type ChoiceStrategy func(eventName string, choices []string) string
+
+// Creates a ChoiceStrategy by name:
+//
+//	first  - always picks the first choice
+//	random - picks a random choice, the same ones for the same seed
+//	script - picks the choices listed in choicesPath, one per line in the
+//	         format "event name: button text", and the first choice for
+//	         all other events
+func NewChoiceStrategy(name string, choicesPath string, seed uint64) (ChoiceStrategy, error) {
+	switch name {
+	case "first":
+		return func(eventName string, choices []string) string {
+			return choices[0]
+		}, nil
+	case "random":
+		rng := rand.New(rand.NewPCG(seed, 0))
+		return func(eventName string, choices []string) string {
+			return choices[rng.IntN(len(choices))]
+		}, nil
+	case "script":
+		scripted, err := readScriptedChoices(choicesPath)
+		if err != nil {
+			return nil, err
+		}
+		return func(eventName string, choices []string) string {
+			choice, ok := scripted[eventName]
+			if !ok {
+				// allow leaving out the mod name in the choices file
+				choice, ok = scripted[getStringAfterSlash(eventName)]
+			}
+			if ok {
+				for _, c := range choices {
+					if c == choice {
+						return c
+					}
+				}
+			}
+			return choices[0]
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown choice strategy: %s", name)
+	}
+}
+
+// Reads a choices file with lines in the format "event name: button text"
+func readScriptedChoices(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the script strategy needs a choices file")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	choices := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		eventName, choice, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: expected 'event name: button text'", path, lineNumber)
+		}
+		choices[strings.TrimSpace(eventName)] = strings.TrimSpace(choice)
+	}
+	return choices, scanner.Err()
+}
+
+// Result of a headless simulation
+type SimulationReport struct {
+	Ticks     int
+	EndReason string
+	Choices   []string
+	Events    map[string]int
+	state     *AppState
+}
+
+func (r *SimulationReport) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Simulated %d ticks (%s)\n", r.Ticks, r.EndReason)
+
+	builder.WriteString("\nStats:\n")
+	for _, name := range []string{"Ticks", "Money", "Food", "FoodMax", "Energy", "EnergyMax", "Mood", "Fitness", "Charisma", "Appearance", "Job", "Salary", "WorkXP"} {
+		fmt.Fprintf(&builder, "  %-12s %v\n", name, r.state.Get(name))
+	}
+
+	variables, err := r.state.Variables.Get()
+	if err == nil && len(variables) > 0 {
+		builder.WriteString("\nVariables:\n")
+		for _, name := range sortedKeys(variables) {
+			fmt.Fprintf(&builder, "  %-12s %v\n", name, variables[name])
+		}
+	}
+
+	if len(r.Choices) > 0 {
+		builder.WriteString("\nChoices:\n")
+		for _, choice := range r.Choices {
+			fmt.Fprintf(&builder, "  %s\n", choice)
+		}
+	}
+
+	if len(r.Events) > 0 {
+		builder.WriteString("\nEvents fired:\n")
+		for _, name := range sortedKeys(r.Events) {
+			fmt.Fprintf(&builder, "  %6d  %s\n", r.Events[name], name)
+		}
+	}
+	return builder.String()
+}
+
+// Food level at which the simulated player buys as much food as possible
+const simulationFoodReserve = 100
+
+// Runs the game for the given number of ticks without a UI, picking the
+// choices of choice events with strategy. Like an attentive player, the
+// simulation buys food whenever it runs low.
+func (state *AppState) simulate(ticks int, strategy ChoiceStrategy) *SimulationReport {
+	report := &SimulationReport{
+		Events: map[string]int{},
+		state:  state,
+	}
+
+	stop := state.countEvents(report.Events)
+	defer stop()
+
+	for report.Ticks < ticks {
+		choiceEventName, _ := state.ChoiceEventName.Get()
+		choices, _ := state.ChoiceEventChoices.Get()
+		if choiceEventName != "" && len(choices) > 0 {
+			choice := strategy(choiceEventName, choices)
+			report.Choices = append(report.Choices, fmt.Sprintf("tick %d: %s -> %s", report.Ticks, choiceEventName, choice))
+			err := state.Choose(choice)
+			if err != nil {
+				report.EndReason = err.Error()
+				return report
+			}
+		}
+
+		if food, _ := state.Food.Get(); food < simulationFoodReserve {
+			state.BuyFood(math.MaxInt)
+		}
+
+		if state.runTicks(1, false) == 0 {
+			if gameOver, _ := state.GameOver.Get(); gameOver {
+				reason, _ := state.GameOverReason.Get()
+				report.EndReason = "game over: " + reason
+			} else {
+				report.EndReason = "paused without a choice"
+			}
+			return report
+		}
+		report.Ticks++
+	}
+	report.EndReason = "finished"
+	return report
+}
+
+// Returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
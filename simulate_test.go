@@ -0,0 +1,109 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// -----------------------------
+// Tests for headless simulation
+// -----------------------------
+
+func newSimulationState(t *testing.T) *AppState {
+	script := `=== Pick a job
+? true
+! paused = true
+* Retail -> Chose Retail
+* Office -> Chose Office
+> true
+
+=== Chose Retail
+! job = Sales clerk
+! paused = false
+> true
+
+=== Chose Office
+! job = Office clerk
+! paused = false
+> true`
+
+	return newScriptedState(t, script)
+}
+
+// Returns a new AppState that only has the events of script
+func newScriptedState(t *testing.T, script string) *AppState {
+	t.Helper()
+	scriptEvents, err := parseScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewAppStateWithDefaults()
+	state.Events = nil
+	for _, scriptEvent := range scriptEvents {
+		state.Events = append(state.Events, scriptEventToEvent(state, scriptEvent))
+	}
+	sortEvents(state.Events)
+	state.scheduleEvents()
+	return state
+}
+
+func TestSimulateFirstStrategy(t *testing.T) {
+	state := newSimulationState(t)
+	strategy, err := NewChoiceStrategy("first", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := state.simulate(50, strategy)
+	if report.EndReason != "finished" || report.Ticks != 50 {
+		t.Errorf("Expected 50 finished ticks, got %d (%s)", report.Ticks, report.EndReason)
+	}
+	if len(report.Choices) != 1 || !strings.HasSuffix(report.Choices[0], "-> Retail") {
+		t.Errorf("Expected the first choice Retail, got %v", report.Choices)
+	}
+	if report.Events["Pick a job"] != 1 {
+		t.Errorf("Expected Pick a job to fire once, got %d", report.Events["Pick a job"])
+	}
+	if job := state.Get("job"); job != "Sales clerk" {
+		t.Errorf("Expected job Sales clerk, got %v", job)
+	}
+	checkBindingInt(t, state.Ticks, 50)
+}
+
+func TestSimulateScriptStrategy(t *testing.T) {
+	choicesPath := filepath.Join(t.TempDir(), "choices.txt")
+	err := os.WriteFile(choicesPath, []byte("# comment\nPick a job: Office\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strategy, err := NewChoiceStrategy("script", choicesPath, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := newSimulationState(t)
+	state.simulate(10, strategy)
+	checkBindingString(t, state.Job, "Office clerk")
+}
+
+func TestSimulateGameOver(t *testing.T) {
+	state := newSimulationState(t)
+	state.Food.Set(20)
+	state.Money.Set(0)
+	strategy, _ := NewChoiceStrategy("random", "", 1)
+
+	report := state.simulate(100, strategy)
+	// the tick that finds no food left ends the game
+	if report.EndReason != "game over: You ran out of food." || report.Ticks != 21 {
+		t.Errorf("Expected game over after 21 ticks, got %d (%s)", report.Ticks, report.EndReason)
+	}
+}
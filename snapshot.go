@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"strings"
+)
+
+// Names of the built-in variables that AppState.Get knows, in lower case
+var builtinVariables = []string{
+	"appearance", "ticks", "work", "workxp", "food", "foodmax", "energy",
+	"energymax", "mood", "money", "charisma", "fitness", "job", "salary",
+	"working", "paused", "gameover", "gameoverreason", "routineshower",
+	"routineshave", "routinebrushteeth", "routinebonus", "eventname",
+	"eventvalue", "eventmax",
+}
+
+// Snapshot is a copy of all variables of an AppState, taken at the start of
+// a tick so the conditions of all events are checked against the same
+// values, no matter in which order they are checked.
+type Snapshot struct {
+	builtins  map[string]interface{}
+	variables map[string]interface{}
+	state     *AppState
+}
+
+// Copies the current values of all variables
+func (a *AppState) Snapshot() *Snapshot {
+	snapshot := &Snapshot{
+		builtins:  make(map[string]interface{}, len(builtinVariables)),
+		variables: map[string]interface{}{},
+		state:     a,
+	}
+	for _, name := range builtinVariables {
+		snapshot.builtins[name] = a.Get(name)
+	}
+	// Variables.Get() still contains deleted variables, so go through the keys
+	for _, key := range a.Variables.Keys() {
+		value, err := a.Variables.GetValue(key)
+		if err == nil {
+			snapshot.variables[key] = value
+		}
+	}
+	return snapshot
+}
+
+func (s *Snapshot) Get(variable string) interface{} {
+	name := strings.ToLower(variable)
+	if name == "rand" {
+		// random numbers can't be copied
+		return s.state.Get(variable)
+	}
+	if value, ok := s.builtins[name]; ok {
+		return value
+	}
+	return s.variables[variable]
+}
@@ -0,0 +1,204 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Maximum number of triggered events that run in a single tick, so events
+// that trigger each other can't freeze the game
+const MaxTriggersPerTick = 100
+
+// An event that runs at a given tick
+type Timer struct {
+	Tick      int    `json:"tick"`
+	EventName string `json:"event"`
+	// Scheduled timers come from the @ line of an event and check the
+	// event's conditions, triggered ones run the event no matter what
+	Scheduled bool `json:"scheduled,omitempty"`
+}
+
+// TimerQueue holds the timers sorted by tick. Timers for the same tick
+// run in the order they were added.
+type TimerQueue struct {
+	mu     sync.Mutex
+	timers []Timer
+}
+
+// Adds a timer that runs eventName at tick
+func (q *TimerQueue) Add(tick int, eventName string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := sort.Search(len(q.timers), func(i int) bool {
+		return q.timers[i].Tick > tick
+	})
+	q.insert(i, Timer{tick, eventName, false})
+}
+
+// Adds a timer for a scheduled event at tick, unless the event already has
+// a scheduled timer
+func (q *TimerQueue) Schedule(tick int, eventName string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, timer := range q.timers {
+		if timer.Scheduled && timer.EventName == eventName {
+			return
+		}
+	}
+	i := sort.Search(len(q.timers), func(i int) bool {
+		return q.timers[i].Tick > tick
+	})
+	q.insert(i, Timer{tick, eventName, true})
+}
+
+// Inserts timer at index i, the caller must hold q.mu
+func (q *TimerQueue) insert(i int, timer Timer) {
+	q.timers = append(q.timers, Timer{})
+	copy(q.timers[i+1:], q.timers[i:])
+	q.timers[i] = timer
+}
+
+// Removes and returns the first timer that is due at tick
+func (q *TimerQueue) Pop(tick int) (Timer, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.timers) == 0 || q.timers[0].Tick > tick {
+		return Timer{}, false
+	}
+	timer := q.timers[0]
+	q.timers = q.timers[1:]
+	return timer, true
+}
+
+// Returns a copy of all timers
+func (q *TimerQueue) Timers() []Timer {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Timer{}, q.timers...)
+}
+
+// Replaces all timers
+func (q *TimerQueue) SetTimers(timers []Timer) {
+	sorted := append([]Timer{}, timers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Tick < sorted[j].Tick
+	})
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.timers = sorted
+}
+
+// Runs the event called eventName after delay ticks. With a delay of 0
+// it runs at the end of the current tick, after all other events.
+func (state *AppState) Trigger(eventName string, delay int) {
+	ticks, err := state.Ticks.Get()
+	if err != nil {
+		fmt.Println("Error getting ticks:", err)
+		return
+	}
+	state.timers.Add(ticks+delay, eventName)
+}
+
+// Runs the triggered and scheduled events that are due at tick
+func (state *AppState) runTimers(tick int) {
+	for range MaxTriggersPerTick {
+		timer, ok := state.timers.Pop(tick)
+		if !ok {
+			return
+		}
+		event := state.GetEvent(timer.EventName)
+		if event == nil {
+			fmt.Println("Error triggering event:", fmt.Errorf("event not found: '%s'", timer.EventName))
+			continue
+		}
+		state.handleEvent(event, !timer.Scheduled)
+
+		if timer.Scheduled && event.Schedule.Kind == "every" && !event.Done {
+			state.timers.Schedule(nextMultiple(tick, event.Schedule.Ticks), event.Name)
+		}
+	}
+}
+
+// Adds the timers of scheduled events that don't have one yet, for
+// example because the game just started or a mod was added to a save
+func (state *AppState) scheduleEvents() {
+	tick, err := state.Ticks.Get()
+	if err != nil {
+		fmt.Println("Error getting ticks:", err)
+		return
+	}
+	for _, event := range state.Events {
+		if event.Schedule == nil || event.Done {
+			continue
+		}
+		switch event.Schedule.Kind {
+		case "every":
+			state.timers.Schedule(nextMultiple(tick, event.Schedule.Ticks), event.Name)
+		case "at":
+			// an event whose tick has passed already ran or was added
+			// too late
+			if event.Schedule.Ticks > tick {
+				state.timers.Schedule(event.Schedule.Ticks, event.Name)
+			}
+		}
+	}
+}
+
+// Updates the timers after the events were reloaded. schedules are the
+// schedules the events had before. Timers of events that were removed are
+// dropped, and scheduled timers of events whose schedule changed are
+// dropped or moved to match the new schedule.
+func (state *AppState) rescheduleEvents(schedules map[string]ScriptSchedule) {
+	var timers []Timer
+	for _, timer := range state.timers.Timers() {
+		event := state.GetEvent(timer.EventName)
+		if event == nil {
+			continue
+		}
+		if timer.Scheduled {
+			old, ok := schedules[timer.EventName]
+			if !ok || event.Schedule == nil {
+				continue
+			}
+			if *event.Schedule != old {
+				// scheduleEvents adds every and at timers again, but an
+				// after timer only knows when the other event ran
+				if old.Kind != "after" || event.Schedule.Kind != "after" || event.Schedule.EventName != old.EventName {
+					continue
+				}
+				timer.Tick += event.Schedule.Ticks - old.Ticks
+			}
+		}
+		timers = append(timers, timer)
+	}
+	state.timers.SetTimers(timers)
+	state.scheduleEvents()
+}
+
+// Schedules the events that run a number of ticks after event
+func (state *AppState) scheduleAfter(event *Event) {
+	for _, other := range state.Events {
+		if other.Schedule == nil || other.Schedule.Kind != "after" || other.Schedule.EventName != event.Name || other.Done {
+			continue
+		}
+		tick, err := state.Ticks.Get()
+		if err != nil {
+			fmt.Println("Error getting ticks:", err)
+			return
+		}
+		state.timers.Schedule(tick+other.Schedule.Ticks, other.Name)
+	}
+}
+
+// Returns the smallest multiple of n that is greater than tick
+func nextMultiple(tick, n int) int {
+	return (tick/n + 1) * n
+}
@@ -0,0 +1,187 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+//
+// The canonical Source Code Repository for this Covered Software is:
+// https://github.com/gitwyrm/idleyou
+
+package main
+
+import (
+	"testing"
+)
+
+// -----------------------------
+// Tests for triggered events
+// -----------------------------
+
+func TestTimerQueueOrder(t *testing.T) {
+	var queue TimerQueue
+	queue.Add(10, "second")
+	queue.Add(5, "first")
+	queue.Add(10, "third")
+	queue.Add(20, "later")
+
+	var names []string
+	for {
+		timer, ok := queue.Pop(10)
+		if !ok {
+			break
+		}
+		names = append(names, timer.EventName)
+	}
+	if len(names) != 3 || names[0] != "first" || names[1] != "second" || names[2] != "third" {
+		t.Errorf("Expected [first second third], got %v", names)
+	}
+	if timers := queue.Timers(); len(timers) != 1 || timers[0].EventName != "later" {
+		t.Errorf("Expected only the later timer to be left, got %v", timers)
+	}
+}
+
+func TestParseTrigger(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ScriptTrigger
+	}{
+		{"trigger Go home", ScriptTrigger{"Go home", 0}},
+		{"trigger Go home in 50", ScriptTrigger{"Go home", 50}},
+		{"trigger Sleep in late", ScriptTrigger{"Sleep in late", 0}},
+	}
+
+	for _, test := range tests {
+		action, err := parseAction(test.input)
+		if err != nil {
+			t.Errorf("parseAction(%q) returned error: %s", test.input, err)
+			continue
+		}
+		if action.Variable != "trigger" || action.Value != test.expected {
+			t.Errorf("parseAction(%q) = %+v, expected %+v", test.input, action, test.expected)
+		}
+	}
+
+	if _, err := parseAction("trigger Go home in -5"); err == nil {
+		t.Errorf("Expected an error for a negative delay")
+	}
+}
+
+func TestTriggerEvents(t *testing.T) {
+	state := newScriptedState(t, `=== Start
+? ticks == 1
+! trigger Now
+! trigger Later in 5
+> true
+
+=== Now
+! mood += 1
+
+=== Later
+! mood += 10
+! trigger Now`)
+	state.Mood.Set(0)
+	state.Food.Set(100)
+
+	state.runTicks(1, false)
+	if mood := state.Get("mood"); mood != 1 {
+		t.Errorf("Expected the triggered event to run in the same tick, mood is %v", mood)
+	}
+
+	state.runTicks(4, false)
+	if mood := state.Get("mood"); mood != 1 {
+		t.Errorf("Expected the delayed event not to run before tick 6, mood is %v", mood)
+	}
+
+	state.runTicks(1, false)
+	if mood := state.Get("mood"); mood != 12 {
+		t.Errorf("Expected the delayed event and its trigger to run at tick 6, mood is %v", mood)
+	}
+}
+
+func TestTimersSurviveSaving(t *testing.T) {
+	state := NewAppStateWithDefaults()
+	state.Ticks.Set(100)
+	state.Trigger("default/Later", 50)
+
+	jsonString, err := state.toJSON()
+	if err != nil {
+		t.Fatalf("Error converting AppState to JSON: %s", err)
+	}
+	loaded, err := fromJSON(jsonString)
+	if err != nil {
+		t.Fatalf("Error loading AppState from JSON: %s", err)
+	}
+
+	timers := loaded.timers.Timers()
+	if len(timers) != 1 || timers[0] != (Timer{150, "default/Later", false}) {
+		t.Errorf("Expected a timer for default/Later at tick 150, got %v", timers)
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ScriptSchedule
+	}{
+		{"every 600", ScriptSchedule{"every", 600, ""}},
+		{"at 1200", ScriptSchedule{"at", 1200, ""}},
+		{"after 100 ticks since Went to bed", ScriptSchedule{"after", 100, "Went to bed"}},
+		{"after 100 since Went to bed", ScriptSchedule{"after", 100, "Went to bed"}},
+	}
+	for _, test := range tests {
+		schedule, err := parseSchedule(test.input)
+		if err != nil {
+			t.Errorf("parseSchedule(%q) returned error: %s", test.input, err)
+			continue
+		}
+		if schedule != test.expected {
+			t.Errorf("parseSchedule(%q) = %+v, expected %+v", test.input, schedule, test.expected)
+		}
+	}
+
+	for _, input := range []string{"every", "every -5", "at 10 20", "after 100 ticks", "sometimes 5"} {
+		if _, err := parseSchedule(input); err == nil {
+			t.Errorf("Expected an error for parseSchedule(%q)", input)
+		}
+	}
+}
+
+func TestScheduledEvents(t *testing.T) {
+	state := newScriptedState(t, `=== Payday
+@ every 10
+! money += 1
+
+=== Once
+@ at 15
+! mood += 1
+
+=== Start
+? ticks == 3
+> true
+
+=== Afterwards
+@ after 5 ticks since Start
+! fitness += 1
+
+=== Only when rich
+@ every 10
+? money > 1000
+! charisma += 1`)
+	state.Money.Set(0)
+	state.Mood.Set(0)
+	state.Fitness.Set(0)
+	state.Charisma.Set(0)
+	state.Food.Set(1000)
+
+	state.runTicks(30, false)
+	if money := state.Get("money"); money != 3 {
+		t.Errorf("Expected the every event to run 3 times, money is %v", money)
+	}
+	if mood := state.Get("mood"); mood != 1 {
+		t.Errorf("Expected the at event to run once, mood is %v", mood)
+	}
+	if fitness := state.Get("fitness"); fitness != 1 {
+		t.Errorf("Expected the after event to run once, fitness is %v", fitness)
+	}
+	if charisma := state.Get("charisma"); charisma != 0 {
+		t.Errorf("Expected the conditions of the scheduled event to be checked, charisma is %v", charisma)
+	}
+}
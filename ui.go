@@ -10,6 +10,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,6 +19,7 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
@@ -49,7 +51,7 @@ func progressBarForBinding(b binding.Int, max binding.Int) *widget.ProgressBar {
 	return progress
 }
 
-func setupUI(appstate *AppState) *fyne.Container {
+func setupUI(appstate *AppState, window fyne.Window) *fyne.Container {
 	// Convert appearance fn to binding for progress bar
 	appearanceBinding := binding.NewInt()
 	appearanceListener := binding.NewDataListener(func() {
@@ -81,10 +83,40 @@ func setupUI(appstate *AppState) *fyne.Container {
 		eventNameLabelBinding.Set(getStringAfterSlash(eventName))
 	}))
 
+	cancelButton := widget.NewButton("Cancel", func() {
+		eventName, err := appstate.ProgressEventName.Get()
+		if err != nil {
+			fmt.Println("Error getting event name:", err)
+			return
+		}
+		appstate.CancelProgressEvent(eventName)
+	})
+
+	// Progress events waiting for the current one, each with a button to
+	// remove it from the queue
+	queueContainer := container.NewVBox()
+	appstate.QueuedEvents.AddListener(binding.NewDataListener(func() {
+		queueContainer.RemoveAll()
+		queuedEvents, err := appstate.QueuedEvents.Get()
+		if err != nil {
+			fmt.Println("Error getting queued events:", err)
+			return
+		}
+		for _, eventName := range queuedEvents {
+			queueContainer.Add(container.NewHBox(
+				widget.NewLabel("Next: "+getStringAfterSlash(eventName)),
+				widget.NewButton("Cancel", func() {
+					appstate.CancelProgressEvent(eventName)
+				}),
+			))
+		}
+	}))
+
 	eventContainer := container.New(
 		layout.NewVBoxLayout(),
-		widget.NewLabelWithData(eventNameLabelBinding),
+		container.NewBorder(nil, nil, nil, cancelButton, widget.NewLabelWithData(eventNameLabelBinding)),
 		progressBarForBinding(appstate.ProgressEventValue, appstate.ProgressEventMax),
+		queueContainer,
 	)
 
 	appstate.ProgressEventName.AddListener(binding.NewDataListener(func() {
@@ -148,71 +180,48 @@ func setupUI(appstate *AppState) *fyne.Container {
 		choiceButtons.RemoveAll()
 		for _, choice := range choiceEventChoices {
 			button := widget.NewButton(choice, func() {
-				currentEventName, err := appstate.ChoiceEventName.Get()
+				err := appstate.Choose(choice)
 				if err != nil {
-					fmt.Println("Error getting current event name:", err)
-					return
+					dialog.ShowError(err, window)
 				}
-				currentEvent := appstate.GetEvent(currentEventName)
-				appstate.ChoiceEventChoices.Set([]string{})
-				appstate.ChoiceEventName.Set("")
-				event := appstate.GetEvent(currentEvent.Choices[choice].EventName)
-				if event == nil {
-					log.Fatalf("Event not found: '%s'", currentEvent.Choices[choice].EventName)
-					return
-				}
-				appstate.handleEvent(event, true)
 			})
 			choiceButtons.Add(button)
 		}
 	}))
 
-	// Add a button to save state
+	// Add buttons to save and load state
 	saveButton := widget.NewButton("Save", func() {
-		jsonData, err := appstate.toJSON()
+		dir, err := savesDir()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		_, err = writeSave(dir, appstate)
 		if err != nil {
-			fmt.Println("Error saving state:", err)
+			dialog.ShowError(err, window)
 			return
 		}
-		fmt.Println("Saved state:", jsonData)
+		appstate.Messages.Prepend("Game saved.")
+	})
+
+	loadButton := widget.NewButton("Load", func() {
+		showLoadDialog(appstate, window)
+	})
+
+	modsButton := widget.NewButton("Mods", func() {
+		showModManager(appstate)
 	})
 
 	buttonRow := container.New(
 		layout.NewHBoxLayout(),
 		widget.NewButton("Buy food ($100)", func() {
-			money, err := appstate.Money.Get()
-			if err != nil {
-				fmt.Println("Error getting money:", err)
-				return
-			}
-			food, err := appstate.Food.Get()
-			if err != nil {
-				fmt.Println("Error getting food:", err)
-				return
-			}
-			if money >= 100 {
-				appstate.Money.Set(money - 100)
-				appstate.Food.Set(food + 100)
-				appstate.FoodMax.Set(food + 100)
+			if appstate.BuyFood(1) > 0 {
 				appstate.Messages.Prepend("You bought food!")
 			}
 		}),
 		widget.NewButton("Buy food (Max)", func() {
-			money, err := appstate.Money.Get()
-			if err != nil {
-				fmt.Println("Error getting money:", err)
-				return
-			}
-			food, err := appstate.Food.Get()
-			if err != nil {
-				fmt.Println("Error getting food:", err)
-				return
-			}
-			ableToPurchase := money / 100
+			ableToPurchase := appstate.BuyFood(math.MaxInt)
 			if ableToPurchase > 0 {
-				appstate.Money.Set(money - ableToPurchase*100)
-				appstate.Food.Set(food + ableToPurchase*100)
-				appstate.FoodMax.Set(food + ableToPurchase*100)
 				appstate.Messages.Prepend(fmt.Sprintf("You bought %v food!", ableToPurchase))
 			}
 		}))
@@ -236,9 +245,7 @@ func setupUI(appstate *AppState) *fyne.Container {
 		for _, key := range keys {
 			value := buttons[key]
 			dynamicButtonRow.Add(widget.NewButton(key, func() {
-				eventName := value.(string)
-				event := appstate.GetEvent(eventName)
-				appstate.handleEvent(event, true)
+				appstate.ClickButton(value.(string))
 			}))
 		}
 	}))
@@ -321,9 +328,152 @@ func setupUI(appstate *AppState) *fyne.Container {
 
 	rightSide := container.New(layout.NewVBoxLayout(), rightLabel, toggles)
 
-	leftSide := container.New(layout.NewVBoxLayout(), container.NewHBox(leftLabel, widget.NewLabel("\t\t\t\t\t")), progressContainer, playerInfo, saveButton)
+	leftSide := container.New(layout.NewVBoxLayout(), container.NewHBox(leftLabel, widget.NewLabel("\t\t\t\t\t")), progressContainer, playerInfo, container.NewHBox(saveButton, loadButton, modsButton))
 
 	center := container.NewBorder(container.New(layout.NewVBoxLayout(), centerLabel, choiceContainer, buttonRow, dynamicButtonRow, eventContainer), nil, nil, nil, messageList)
 
-	return container.NewBorder(nil, nil, leftSide, rightSide, center)
+	gameContent := container.NewBorder(nil, nil, leftSide, rightSide, center)
+	gameOverContent := gameOverScreen(appstate)
+
+	appstate.GameOver.AddListener(binding.NewDataListener(func() {
+		gameOver, err := appstate.GameOver.Get()
+		if err != nil {
+			fmt.Println("Error getting game over:", err)
+			return
+		}
+		if gameOver {
+			gameContent.Hide()
+			gameOverContent.Show()
+		} else {
+			gameOverContent.Hide()
+			gameContent.Show()
+		}
+	}))
+
+	return container.NewStack(gameContent, gameOverContent)
+}
+
+// Creates the screen that is shown instead of the game once it is over,
+// with the final stats and a button to start a new game
+func gameOverScreen(appstate *AppState) *fyne.Container {
+	title := widget.NewLabel("Game Over")
+	title.TextStyle.Bold = true
+	title.Alignment = fyne.TextAlignCenter
+
+	reason := widget.NewLabelWithData(appstate.GameOverReason)
+	reason.Alignment = fyne.TextAlignCenter
+
+	stats := container.New(
+		layout.NewFormLayout(),
+		widget.NewLabel("Ticks survived:"), widget.NewLabelWithData(binding.IntToString(appstate.Ticks)),
+		widget.NewLabel("Job:"), widget.NewLabelWithData(appstate.Job),
+		widget.NewLabel("Job experience:"), widget.NewLabelWithData(binding.IntToString(appstate.WorkXP)),
+		widget.NewLabel("Money:"), widget.NewLabelWithData(binding.IntToString(appstate.Money)),
+	)
+
+	newGameButton := widget.NewButton("New game", func() {
+		appstate.replaceWith(NewAppStateWithDefaults())
+	})
+
+	screen := container.NewCenter(container.NewVBox(title, reason, container.NewCenter(stats), newGameButton))
+	screen.Hide()
+	return screen
+}
+
+// Shows a dialog listing all save files, the selected save replaces the
+// current game
+func showLoadDialog(appstate *AppState, window fyne.Window) {
+	dir, err := savesDir()
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	saves, err := listSaves(dir)
+	if err != nil {
+		dialog.ShowError(err, window)
+		return
+	}
+	if len(saves) == 0 {
+		dialog.ShowInformation("Load", "There are no saves yet.", window)
+		return
+	}
+
+	// show the file names only, newest first
+	names := make([]string, 0, len(saves))
+	for _, save := range saves {
+		names = append(names, filepath.Base(save))
+	}
+	saveSelect := widget.NewSelect(names, nil)
+	saveSelect.SetSelectedIndex(0)
+
+	dialog.ShowCustomConfirm("Load", "Load", "Cancel", saveSelect, func(confirmed bool) {
+		if !confirmed || saveSelect.SelectedIndex() < 0 {
+			return
+		}
+		loaded, err := loadSave(saves[saveSelect.SelectedIndex()])
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		// Offline progress is only applied to the latest save when the game
+		// starts, otherwise loading an old save again and again would give
+		// hours of free progress every time
+		appstate.replaceWith(loaded)
+	}, window)
+}
+
+// Adds the summary of offline progress to the message log and shows it
+// in a dialog
+func showOfflineSummary(appstate *AppState, summary *OfflineSummary, window fyne.Window) {
+	appstate.Messages.Prepend(summary.String())
+	dialog.ShowInformation("Welcome back", summary.String(), window)
+}
+
+// Shows a window listing all mods with a toggle to enable or disable each
+// of them. The changes are saved to the mod config and used the next time
+// the scripts are read.
+func showModManager(appstate *AppState) {
+	window := fyne.CurrentApp().NewWindow("Mods")
+
+	configPath := modConfigPath()
+	config, err := loadModConfig(configPath)
+	if err != nil {
+		dialog.ShowError(err, window)
+	}
+
+	statuses := appstate.ModStatuses()
+	rows := container.NewVBox()
+	for _, status := range statuses {
+		check := widget.NewCheck(status.DisplayName(), nil)
+		check.Checked = status.Enabled
+		check.OnChanged = func(enabled bool) {
+			config.SetEnabled(status.Name, enabled)
+			if err := saveModConfig(configPath, config); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}
+		info := widget.NewLabel(status.Summary())
+		row := container.NewVBox(check, info)
+		if status.Manifest.Description != "" {
+			description := widget.NewLabel(status.Manifest.Description)
+			description.Wrapping = fyne.TextWrapWord
+			row.Add(description)
+		}
+		if len(status.Errors) > 0 {
+			errorsLabel := widget.NewLabel(status.Errors.Error())
+			errorsLabel.Importance = widget.DangerImportance
+			errorsLabel.Wrapping = fyne.TextWrapWord
+			row.Add(errorsLabel)
+		}
+		rows.Add(row)
+		rows.Add(widget.NewSeparator())
+	}
+	if len(statuses) == 0 {
+		rows.Add(widget.NewLabel("No mods found, the game uses its built-in script."))
+	}
+
+	hint := widget.NewLabel("Changes are applied right away, reopen this window to see them.")
+	window.SetContent(container.NewBorder(nil, hint, nil, nil, container.NewVScroll(rows)))
+	window.Resize(fyne.NewSize(500, 400))
+	window.Show()
 }
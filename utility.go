@@ -15,45 +15,8 @@ import (
 	"strings"
 )
 
-// ConcatenateTxtFiles scans the given directory (and its subdirectories) for .txt files.
-// For each file found, it reads the content, extracts the modName, and
-// then passes both the file content and the modName to the provided callback function.
-// The string returned by the callback is concatenated into the final result.
-func ConcatenateTxtFiles(rootPath string, callback func(text, modName string) string) (string, error) {
-	var builder strings.Builder
-
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.EqualFold(filepath.Ext(info.Name()), ".txt") {
-			// Only process if the parent dir is "scripts"
-			parentDir := filepath.Base(filepath.Dir(path))
-			if parentDir != "scripts" {
-				return nil // Skip this file
-			}
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return err
-			}
-			// Mod name is the dir above "scripts"
-			modName := filepath.Base(filepath.Dir(filepath.Dir(path)))
-			if modName == "mods" { // Root-level check
-				modName = ""
-			}
-			processed := callback(string(data), modName)
-			builder.WriteString(processed)
-			builder.WriteString("\n")
-		}
-		return nil
-	})
-	if err != nil {
-		return "", err
-	}
-	return builder.String(), nil
-}
-
-func readScript() string {
+// Returns the path of the mods folder, creating it if it doesn't exist yet
+func modsDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal("Could not determine user home directory:", err)
@@ -63,53 +26,272 @@ func readScript() string {
 	if err != nil {
 		log.Fatal("Could not create mods folder:", err)
 	}
+	return modPath
+}
+
+// Reads and parses the scripts of all enabled mods in the order they are
+// loaded in, see findMods. A mod with errors in its manifest or in any of its
+// script files is skipped, the returned errors describe what is wrong with it
+// so the player can be warned. The returned statuses describe all mods,
+// including the disabled ones, for the mod manager.
+func readScript() ([]ModStatus, []ScriptEvent, ScriptErrors) {
+	modPath := modsDir()
+	config, err := loadModConfig(modConfigPath())
+	if err != nil {
+		log.Printf("Error reading mod config, all mods are enabled: %v\n", err)
+	}
+
+	mods, manifestErrors := findMods(modPath)
+	if len(mods) == 0 && len(manifestErrors) == 0 {
+		// Fallback to default mod, prefixed like it is when it is read
+		// from the folder on the next start so saves match
+		writeDefaultMod(modPath)
+		events, err := parseScript(defaultScript())
+		if err != nil {
+			log.Fatal("Error parsing embedded script:", err)
+		}
+		// the mod manager shows the default mod like it will after a restart
+		status := ModStatus{
+			Mod:     Mod{Name: "default", Dir: filepath.Join(modPath, "default")},
+			Enabled: true,
+			Loaded:  true,
+			Events:  len(events),
+		}
+		return []ModStatus{status}, prefixModName(events, "default"), nil
+	}
 
-	script, err := ConcatenateTxtFiles(modPath, func(text, modName string) string {
-		var builder strings.Builder
-		if modName == "" || modName == "mods" { // Skip root-level prefixing
-			return text
+	var statuses []*ModStatus
+	byName := map[string]*ModStatus{}
+	for _, mod := range mods {
+		status := &ModStatus{Mod: mod, Enabled: config.Enabled(mod.Name)}
+		statuses = append(statuses, status)
+		byName[mod.Name] = status
+	}
+	var errs ScriptErrors
+	for _, err := range manifestErrors {
+		status, ok := byName[err.Mod]
+		if !ok {
+			status = &ModStatus{Mod: Mod{Name: err.Mod}, Enabled: config.Enabled(err.Mod)}
+			statuses = append(statuses, status)
+			byName[err.Mod] = status
+		}
+		status.Errors = append(status.Errors, err)
+		if status.Enabled {
+			errs = append(errs, err)
+		}
+	}
+
+	// Disabled mods are read too, so the mod manager can show their events
+	// and errors
+	var readMods []Mod
+	modEvents := map[string][]ScriptEvent{}
+	for _, mod := range mods {
+		status := byName[mod.Name]
+		events, modErrors := readMod(modPath, mod)
+		status.Events = len(events)
+		status.Errors = append(status.Errors, modErrors...)
+		if !status.Enabled {
+			continue
+		}
+		if len(modErrors) > 0 {
+			errs = append(errs, modErrors...)
+			continue
+		}
+		readMods = append(readMods, mod)
+		modEvents[mod.Name] = events
+	}
+
+	readMods, overrideErrors := checkModifiedEvents(readMods, modEvents)
+	errs = append(errs, overrideErrors...)
+	loaded, dependencyErrors := resolveDependencies(readMods)
+	errs = append(errs, dependencyErrors...)
+	for _, err := range append(overrideErrors, dependencyErrors...) {
+		byName[err.Mod].Errors = append(byName[err.Mod].Errors, err)
+	}
+
+	var events []ScriptEvent
+	result := make([]ModStatus, 0, len(statuses))
+	for _, mod := range loaded {
+		byName[mod.Name].Loaded = true
+		events = append(events, modEvents[mod.Name]...)
+	}
+	for _, status := range statuses {
+		result = append(result, *status)
+	}
+	return result, events, errs
+}
+
+// Reads and parses the script files of a single mod in the order of their
+// file names
+func readMod(modPath string, mod Mod) ([]ScriptEvent, ScriptErrors) {
+	scriptsDir := filepath.Join(mod.Dir, "scripts")
+	entries, err := os.ReadDir(scriptsDir)
+	if err != nil {
+		return nil, ScriptErrors{{Mod: mod.Name, File: mod.Name, Message: err.Error()}}
+	}
+
+	var events []ScriptEvent
+	var errs ScriptErrors
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".txt") {
+			continue
+		}
+		path := filepath.Join(scriptsDir, entry.Name())
+		relPath, relErr := filepath.Rel(modPath, path)
+		if relErr != nil {
+			relPath = path
 		}
-		for _, line := range strings.Split(text, "\n") {
-			if strings.HasPrefix(line, "=== ") {
-				line = fmt.Sprintf("=== %s/%s", modName, line[4:])
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, &ScriptError{Mod: mod.Name, File: relPath, Message: err.Error()})
+			continue
+		}
+		fileEvents, err := parseScript(string(data))
+		if scriptErrors, ok := err.(ScriptErrors); ok {
+			for _, scriptError := range scriptErrors {
+				scriptError.Mod = mod.Name
+				scriptError.File = relPath
 			}
-			if strings.HasPrefix(line, "! show ") {
-				line = fmt.Sprintf("! show %s/images/%s", modName, line[7:])
+			errs = append(errs, scriptErrors...)
+		}
+		events = append(events, prefixModName(fileEvents, mod.Name)...)
+	}
+
+	// Events of other mods can only be used if they are loaded first
+	for _, eventName := range eventReferences(events) {
+		other, _, found := strings.Cut(eventName, "/")
+		if found && !mod.canReference(other) {
+			errs = append(errs, &ScriptError{Mod: mod.Name, File: mod.Name, Message: fmt.Sprintf("%s is an event of mod %s, add %s to the dependencies in the manifest", eventName, other, other)})
+		}
+	}
+	return events, errs
+}
+
+// Prefixes the event names, the images and the events linked by buttons
+// and choices with the mod name, so mods don't interfere with each other.
+// Links to events of other mods like default/Chose Retail already have a
+// mod name and are left as they are.
+func prefixModName(events []ScriptEvent, modName string) []ScriptEvent {
+	if modName == "" { // Skip root-level prefixing
+		return events
+	}
+	for i := range events {
+		event := &events[i]
+		if event.Modifies != "" {
+			event.Name = prefixEventName(event.Name, modName)
+		} else {
+			event.Name = fmt.Sprintf("%s/%s", modName, event.Name)
+		}
+		prefixActions(event.ScriptActions, event.ScriptButtons, modName)
+		if event.Schedule != nil && event.Schedule.EventName != "" {
+			event.Schedule.EventName = prefixEventName(event.Schedule.EventName, modName)
+		}
+		for key, choice := range event.Choices {
+			choice.EventName = prefixEventName(choice.EventName, modName)
+			event.Choices[key] = choice
+		}
+	}
+	return events
+}
+
+// Prefixes a link to an event with the mod name, unless it links to an
+// event of another mod
+func prefixEventName(eventName, modName string) string {
+	if strings.Contains(eventName, "/") {
+		return eventName
+	}
+	return fmt.Sprintf("%s/%s", modName, eventName)
+}
+
+// Returns the names of the events linked by buttons, choices, triggers and
+// schedules of events and of the events changed by overrides and patches
+func eventReferences(events []ScriptEvent) []string {
+	var names []string
+	var addActions func(actions []ScriptAction, buttons []ScriptButton)
+	addActions = func(actions []ScriptAction, buttons []ScriptButton) {
+		for _, action := range actions {
+			if action.Operator != "" {
+				continue
 			}
-			if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "*") {
-				// prefix the eventName after the -> with the modName
-				parts := strings.Split(line, "->")
-				if len(parts) == 2 {
-					line = fmt.Sprintf("%s -> %s/%s", strings.Trim(parts[0], " "), modName, strings.Trim(parts[1], " "))
+			switch action.Variable {
+			case "trigger":
+				names = append(names, action.Value.(ScriptTrigger).EventName)
+			case "if", "random":
+				for _, branch := range action.Value.([]ScriptBranch) {
+					addActions(branch.ScriptActions, branch.ScriptButtons)
 				}
 			}
-			builder.WriteString(line)
-			builder.WriteString("\n")
 		}
-		return builder.String()
-	})
-	if err != nil {
-		log.Printf("Error reading mod script files: %v\n", err)
+		for _, button := range buttons {
+			if button.EventName != "" {
+				names = append(names, button.EventName)
+			}
+		}
 	}
-	if script != "" {
-		return script
+	for _, event := range events {
+		if event.Modifies != "" {
+			names = append(names, event.Name)
+		}
+		addActions(event.ScriptActions, event.ScriptButtons)
+		if event.Schedule != nil && event.Schedule.EventName != "" {
+			names = append(names, event.Schedule.EventName)
+		}
+		for _, choice := range event.Choices {
+			names = append(names, choice.EventName)
+		}
 	}
+	return names
+}
 
-	// Fallback to default mod
+// Prefixes the images of show actions and the events linked by buttons
+// and triggers with the mod name, including the ones inside of if and
+// random blocks
+func prefixActions(actions []ScriptAction, buttons []ScriptButton, modName string) {
+	for i, action := range actions {
+		if action.Operator != "" {
+			continue
+		}
+		switch action.Variable {
+		case "show":
+			actions[i].Value = fmt.Sprintf("%s/images/%s", modName, action.Value)
+		case "trigger":
+			trigger := action.Value.(ScriptTrigger)
+			trigger.EventName = prefixEventName(trigger.EventName, modName)
+			actions[i].Value = trigger
+		case "if", "random":
+			for _, branch := range action.Value.([]ScriptBranch) {
+				prefixActions(branch.ScriptActions, branch.ScriptButtons, modName)
+			}
+		}
+	}
+	for i, button := range buttons {
+		if button.EventName != "" {
+			buttons[i].EventName = prefixEventName(button.EventName, modName)
+		}
+	}
+}
+
+// Returns the embedded script of the default mod
+func defaultScript() string {
 	data, err := scriptFile.ReadFile("script.txt")
 	if err != nil {
 		log.Fatal("Error reading embedded script:", err)
 	}
+	return string(data)
+}
+
+// Writes the embedded script to the default mod folder, so players can
+// look at it and change it
+func writeDefaultMod(modPath string) {
 	defaultModScriptPath := filepath.Join(modPath, "default", "scripts")
-	err = os.MkdirAll(defaultModScriptPath, 0755)
+	err := os.MkdirAll(defaultModScriptPath, 0755)
 	if err != nil {
 		log.Fatal("Could not create default mod scripts folder:", err)
 	}
-	err = os.WriteFile(filepath.Join(defaultModScriptPath, "script.txt"), data, 0644)
+	err = os.WriteFile(filepath.Join(defaultModScriptPath, "script.txt"), []byte(defaultScript()), 0644)
 	if err != nil {
 		log.Fatal("Error writing default script file:", err)
 	}
-	return string(data)
 }
 
 func getStringAfterSlash(s string) string {